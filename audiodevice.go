@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// Вибирає пристрій виводу звуку перед ініціалізацією динаміка. Бібліотека oto, через яку beep
+// відтворює звук, завжди відкриває пристрій ALSA "default" і не дозволяє перелічити пристрої,
+// тому на Linux пристрій вибирається змінною середовища ALSA_CARD, яку читає конфігурація ALSA.
+// На інших системах звук виводиться на пристрій за замовчуванням
+func selectAudioDevice(config *Config) {
+	if config.AudioDevice == "" {
+		return
+	}
+	if runtime.GOOS != "linux" {
+		logWarn("Попередження: audio_device підтримується лише на Linux, звук виводиться на пристрій за замовчуванням")
+		return
+	}
+	if err := os.Setenv("ALSA_CARD", config.AudioDevice); err != nil {
+		logWarn("Попередження: не вдалося вибрати аудіопристрій %s: %v", config.AudioDevice, err)
+	}
+}
+
+// Опис пристрою виводу для логу
+func audioDeviceName(config *Config) string {
+	if runtime.GOOS == "linux" {
+		if card := os.Getenv("ALSA_CARD"); card != "" {
+			return "ALSA_CARD=" + card
+		}
+	}
+	return "за замовчуванням"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Останнє значення для відповідей без мітки часу. Використовується лише з горутини запитів
+var booleanLast struct {
+	known  bool
+	active bool
+	since  string
+}
+
+// Декодує відповідь виду {"alert": true}. Істинне значення поля означає одну активну
+// тривогу вказаного типу, хибне - відсутність тривог
+func decodeBoolean(r io.Reader, config *Config) ([]Alert, string, error) {
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, "", err
+	}
+
+	field := config.BooleanField
+	if field == "" {
+		field = "alert" // Значення за замовчуванням
+	}
+	value, ok := lookupField(payload, field)
+	if !ok {
+		return nil, "", fmt.Errorf("поле %s відсутнє у відповіді", field)
+	}
+	active := isTruthy(value)
+
+	// Час беремо з відповіді, якщо він є, інакше - момент зміни значення
+	lastUpdate := ""
+	if config.BooleanTimeField != "" {
+		if t, ok := lookupField(payload, config.BooleanTimeField); ok {
+			lastUpdate = booleanTime(t)
+		}
+	}
+	if lastUpdate == "" {
+		if !booleanLast.known || booleanLast.active != active {
+			booleanLast.known = true
+			booleanLast.active = active
+			booleanLast.since = time.Now().UTC().Format(time.RFC3339)
+		}
+		lastUpdate = booleanLast.since
+	}
+
+	if !active {
+		return nil, lastUpdate, nil
+	}
+
+	alertType := config.BooleanAlertType
+	if alertType == "" {
+		alertType = "AIR" // Значення за замовчуванням
+	}
+	return []Alert{{Type: alertType, LastUpdate: lastUpdate}}, lastUpdate, nil
+}
+
+// Перетворює час з відповіді у рядок. Числа вважаються Unix часом у секундах
+// (або мілісекундах для великих значень) і перетворюються у RFC3339, рядки лишаються як є
+func booleanTime(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		if v > 1e12 {
+			return time.UnixMilli(int64(v)).UTC().Format(time.RFC3339)
+		}
+		return time.Unix(int64(v), 0).UTC().Format(time.RFC3339)
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Шукає значення за шляхом через крапку, наприклад "data.alert"
+func lookupField(payload map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "yes", "on":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeBoolean(t *testing.T) {
+	config := &Config{BooleanField: "data.alert", BooleanAlertType: "AIR", BooleanTimeField: "data.updated"}
+	tests := []struct {
+		name       string
+		payload    string
+		wantAlerts int
+		wantUpdate string
+		wantErr    bool
+	}{
+		{"true", `{"data": {"alert": true, "updated": "2024-05-01T10:00:00Z"}}`, 1, "2024-05-01T10:00:00Z", false},
+		{"false", `{"data": {"alert": false, "updated": "2024-05-01T10:00:00Z"}}`, 0, "2024-05-01T10:00:00Z", false},
+		{"string true", `{"data": {"alert": "yes", "updated": "2024-05-01T10:00:00Z"}}`, 1, "2024-05-01T10:00:00Z", false},
+		{"unix seconds", `{"data": {"alert": 1, "updated": 1714557600}}`, 1, "2024-05-01T10:00:00Z", false},
+		{"unix milliseconds", `{"data": {"alert": 1, "updated": 1714557600000}}`, 1, "2024-05-01T10:00:00Z", false},
+		{"missing field", `{"data": {"updated": "2024-05-01T10:00:00Z"}}`, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts, lastUpdate, err := decodeBoolean(strings.NewReader(tt.payload), config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(alerts) != tt.wantAlerts {
+				t.Fatalf("alerts = %v, want %d", alerts, tt.wantAlerts)
+			}
+			if lastUpdate != tt.wantUpdate {
+				t.Errorf("lastUpdate = %q, want %q", lastUpdate, tt.wantUpdate)
+			}
+			if len(alerts) == 1 && (alerts[0].Type != "AIR" || alerts[0].LastUpdate != tt.wantUpdate) {
+				t.Errorf("alert = %+v", alerts[0])
+			}
+			if tt.wantUpdate != "" {
+				if _, err := parseAPITime(lastUpdate); err != nil {
+					t.Errorf("parseAPITime(%q): %v", lastUpdate, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// Структури документа CAP (Common Alerting Protocol). Теги без простору імен,
+// тому підходять як для CAP 1.1, так і для CAP 1.2
+type capAlert struct {
+	Identifier string    `xml:"identifier"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Info       []capInfo `xml:"info"`
+}
+
+type capInfo struct {
+	Event     string         `xml:"event"`
+	EventCode []capValuePair `xml:"eventCode"`
+	Effective string         `xml:"effective"`
+	Expires   string         `xml:"expires"`
+	Area      []capArea      `xml:"area"`
+}
+
+type capValuePair struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+type capArea struct {
+	AreaDesc string `xml:"areaDesc"`
+}
+
+// Документ може містити одне повідомлення <alert> або кілька повідомлень у контейнері (наприклад, Atom feed)
+type capFeed struct {
+	Alerts  []capAlert `xml:"alert"`
+	Entries []struct {
+		Content struct {
+			Alerts []capAlert `xml:"alert"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+func isXMLContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.Contains(contentType, "/xml") || strings.Contains(contentType, "+xml")
+}
+
+func decodeCAP(r io.Reader, config *Config) ([]Alert, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var messages []capAlert
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, "", err
+	}
+	if root.XMLName.Local == "alert" {
+		var message capAlert
+		if err := xml.Unmarshal(data, &message); err != nil {
+			return nil, "", err
+		}
+		messages = append(messages, message)
+	} else {
+		var feed capFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, "", err
+		}
+		messages = append(messages, feed.Alerts...)
+		for _, entry := range feed.Entries {
+			messages = append(messages, entry.Content.Alerts...)
+		}
+	}
+
+	now := time.Now()
+	var alerts []Alert
+	lastUpdate := ""
+	for _, message := range messages {
+		if message.Sent > lastUpdate {
+			lastUpdate = message.Sent
+		}
+		// Враховуємо лише реальні повідомлення, скасування означає відсутність події
+		if !strings.EqualFold(message.Status, "Actual") || strings.EqualFold(message.MsgType, "Cancel") {
+			continue
+		}
+		for _, info := range message.Info {
+			if info.Expires != "" {
+				if expires, err := parseAPITime(info.Expires); err == nil && expires.Before(now) {
+					continue
+				}
+			}
+			if !capAreaMatches(info.Area, config.CAPAreaFilter) {
+				continue
+			}
+			alertType := capAlertType(info, config.CAPEventCode)
+			if alertType == "" {
+				continue
+			}
+			alerts = append(alerts, Alert{Type: alertType, LastUpdate: message.Sent, Region: capAreaName(info.Area, config.CAPAreaFilter)})
+		}
+	}
+
+	if len(alerts) > 0 {
+		return alerts, alerts[0].LastUpdate, nil
+	}
+	return nil, lastUpdate, nil
+}
+
+// Визначає тип події: значення eventCode з вказаною назвою, або перший eventCode, або назва події
+func capAlertType(info capInfo, valueName string) string {
+	for _, code := range info.EventCode {
+		if valueName == "" || strings.EqualFold(code.ValueName, valueName) {
+			return strings.TrimSpace(code.Value)
+		}
+	}
+	return strings.ToUpper(strings.TrimSpace(info.Event))
+}
+
+func capAreaMatches(areas []capArea, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, area := range areas {
+		if strings.Contains(strings.ToLower(area.AreaDesc), strings.ToLower(filter)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Назва області повідомлення для подій: перша, що відповідає фільтру, або перша у списку
+func capAreaName(areas []capArea, filter string) string {
+	for _, area := range areas {
+		if filter == "" || strings.Contains(strings.ToLower(area.AreaDesc), strings.ToLower(filter)) {
+			return area.AreaDesc
+		}
+	}
+	return ""
+}
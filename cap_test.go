@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const capSample = `<?xml version="1.0" encoding="UTF-8"?>
+<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+  <identifier>UA-2024-0001</identifier>
+  <sent>2024-05-01T10:00:00+03:00</sent>
+  <status>%STATUS%</status>
+  <msgType>%MSGTYPE%</msgType>
+  <info>
+    <event>Air raid</event>
+    <eventCode><valueName>SIGNAL</valueName><value>AIR</value></eventCode>
+    <expires>%EXPIRES%</expires>
+    <area><areaDesc>Київська область</areaDesc></area>
+  </info>
+  <info>
+    <event>Artillery</event>
+    <area><areaDesc>Харківська область</areaDesc></area>
+  </info>
+</alert>`
+
+func capDocument(status, msgType, expires string) string {
+	return strings.NewReplacer("%STATUS%", status, "%MSGTYPE%", msgType, "%EXPIRES%", expires).Replace(capSample)
+}
+
+func TestDecodeCAP(t *testing.T) {
+	tests := []struct {
+		name      string
+		document  string
+		config    Config
+		want      []Alert
+		wantError bool
+	}{
+		{
+			name:     "event code and event name",
+			document: capDocument("Actual", "Alert", "2999-01-01T00:00:00Z"),
+			config:   Config{CAPEventCode: "SIGNAL"},
+			want: []Alert{
+				{Type: "AIR", LastUpdate: "2024-05-01T10:00:00+03:00", Region: "Київська область"},
+				{Type: "ARTILLERY", LastUpdate: "2024-05-01T10:00:00+03:00", Region: "Харківська область"},
+			},
+		},
+		{
+			name:     "area filter",
+			document: capDocument("Actual", "Alert", "2999-01-01T00:00:00Z"),
+			config:   Config{CAPAreaFilter: "київ"},
+			want:     []Alert{{Type: "AIR", LastUpdate: "2024-05-01T10:00:00+03:00", Region: "Київська область"}},
+		},
+		{
+			name:     "expired info",
+			document: capDocument("Actual", "Alert", "2000-01-01T00:00:00Z"),
+			want:     []Alert{{Type: "ARTILLERY", LastUpdate: "2024-05-01T10:00:00+03:00", Region: "Харківська область"}},
+		},
+		{
+			name:     "cancel",
+			document: capDocument("Actual", "Cancel", ""),
+		},
+		{
+			name:     "exercise",
+			document: capDocument("Exercise", "Alert", ""),
+		},
+		{
+			name:     "feed",
+			document: "<feed><entry><content>" + strings.TrimPrefix(capDocument("Actual", "Alert", ""), `<?xml version="1.0" encoding="UTF-8"?>`) + "</content></entry></feed>",
+			config:   Config{CAPAreaFilter: "Харків"},
+			want:     []Alert{{Type: "ARTILLERY", LastUpdate: "2024-05-01T10:00:00+03:00", Region: "Харківська область"}},
+		},
+		{
+			name:      "malformed",
+			document:  "<alert><info>",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts, lastUpdate, err := decodeCAP(strings.NewReader(tt.document), &tt.config)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("decodeCAP error = %v, want error %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if !slices.Equal(alerts, tt.want) {
+				t.Errorf("alerts = %+v, want %+v", alerts, tt.want)
+			}
+			if lastUpdate != "2024-05-01T10:00:00+03:00" {
+				t.Errorf("lastUpdate = %q, want the sent time", lastUpdate)
+			}
+		})
+	}
+}
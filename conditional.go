@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Попередня відповідь джерела разом з валідаторами HTTP кешу
+type conditionalEntry struct {
+	ETag         string
+	LastModified string
+	Alerts       []Alert
+	LastUpdate   string
+}
+
+// Зберігає ETag та Last-Modified для умовних запитів, щоб не завантажувати незмінені дані
+type conditionalCache struct {
+	mu      sync.Mutex
+	entries map[string]*conditionalEntry
+}
+
+var conditional = &conditionalCache{entries: make(map[string]*conditionalEntry)}
+
+// Додає до запиту заголовки If-None-Match та If-Modified-Since
+func (c *conditionalCache) apply(req *http.Request, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[url]
+	if !ok {
+		return
+	}
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// Запамʼятовує розібрану відповідь, якщо сервер надав валідатори
+func (c *conditionalCache) store(url string, header http.Header, alerts []Alert, lastUpdate string) {
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if etag == "" && lastModified == "" {
+		delete(c.entries, url)
+		return
+	}
+	c.entries[url] = &conditionalEntry{ETag: etag, LastModified: lastModified, Alerts: alerts, LastUpdate: lastUpdate}
+}
+
+// Повертає попередню відповідь для відповіді 304
+func (c *conditionalCache) cached(url string) ([]Alert, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[url]
+	if !ok {
+		return nil, "", false
+	}
+	return append([]Alert(nil), entry.Alerts...), entry.LastUpdate, true
+}
+
+// Забуває всі попередні відповіді, наприклад після зміни налаштувань розбору
+func (c *conditionalCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*conditionalEntry)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gen2brain/beeep"
+)
+
+// Показує системне сповіщення про початок або закінчення тривоги.
+// Якщо сповіщення недоступні (наприклад, на сервері без графічного середовища), помилка лише записується у лог
+func notifyDesktop(config *Config, event AlertEvent) {
+	if !config.DesktopNotify {
+		return
+	}
+
+	var title string
+	switch event.Kind {
+	case "start":
+		title = "Тривога"
+	case "end":
+		title = "Відбій тривоги"
+	default:
+		return
+	}
+	body := fmt.Sprintf("%s, %s", event.AlertType, convertToLocalTime(event.Time, config.TimeZone))
+
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		if err := beeep.Notify(title, body, ""); err != nil {
+			logWarn("Не вдалося показати системне сповіщення: %v", err)
+		}
+	}()
+}
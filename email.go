@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPConfig - налаштування поштових сповіщень
+type SMTPConfig struct {
+	Host           string   `json:"host"`
+	Port           int      `json:"port"`
+	Username       string   `json:"username"`
+	Password       string   `json:"password"`
+	From           string   `json:"from"`
+	To             []string `json:"to"`
+	MinIntervalSec int      `json:"min_interval_sec"` // Мінімальна пауза між листами про той самий тип і подію
+}
+
+// Граничний час зʼєднання з поштовим сервером
+const smtpTimeout = 30 * time.Second
+
+// Пауза між листами про той самий тип і подію за замовчуванням
+const defaultEmailIntervalSec = 300
+
+// emailLimiter памʼятає час останнього листа, щоб тривога, що то зникає, то зʼявляється, не засипала пошту
+type emailLimiter struct {
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+var emailLimit = &emailLimiter{sent: make(map[string]time.Time)}
+
+// Чи можна надіслати лист про подію key. Якщо так, запамʼятовує час
+func (l *emailLimiter) allow(key string, interval time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.sent[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	l.sent[key] = now
+	return true
+}
+
+// Формує тему і текст листа про початок або закінчення тривоги
+func emailMessage(config *Config, event AlertEvent) (string, string) {
+	localTime := convertToLocalTime(event.Time, config.TimeZone)
+	var subject, body string
+	switch event.Kind {
+	case "start":
+		subject = "Тривога: " + event.AlertType
+		body = fmt.Sprintf("Тип: %s\nПочаток: %s\n", event.AlertType, localTime)
+	case "end":
+		subject = "Відбій: " + event.AlertType
+		lasted := "невідомо"
+		if event.Duration > 0 {
+			lasted = formatDuration(event.Duration)
+		}
+		body = fmt.Sprintf("Тип: %s\nЗакінчення: %s\nТривалість: %s\n", event.AlertType, localTime, lasted)
+	}
+	if config.InstanceName != "" {
+		subject = "[" + config.InstanceName + "] " + subject
+	}
+	return subject, body
+}
+
+// Надсилає лист про початок або закінчення тривоги у окремій горутині. Помилка лише записується у лог
+func notifyEmail(config *Config, event AlertEvent) {
+	smtpConfig := config.SMTP
+	if smtpConfig == nil || smtpConfig.Host == "" || len(smtpConfig.To) == 0 {
+		return
+	}
+	if event.Kind != "start" && event.Kind != "end" {
+		return
+	}
+	interval := time.Duration(smtpConfig.MinIntervalSec) * time.Second
+	if smtpConfig.MinIntervalSec <= 0 {
+		interval = defaultEmailIntervalSec * time.Second
+	}
+	if !emailLimit.allow(event.AlertType+"/"+event.Kind, interval, time.Now()) {
+		log.Printf("Лист про подію %s (%s) не надіслано: попередній надіслано менше ніж %s тому", event.AlertType, event.Kind, interval)
+		return
+	}
+	subject, body := emailMessage(config, event)
+	sendEmailAsync(smtpConfig, subject, body)
+}
+
+// Надсилає лист у окремій горутині. Помилка лише записується у лог
+func sendEmailAsync(config *SMTPConfig, subject, body string) {
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		if err := sendEmail(config, subject, body); err != nil {
+			logWarn("Попередження: не вдалося надіслати лист: %v", err)
+		}
+	}()
+}
+
+// Надсилає лист через SMTP. Порт 465 - зʼєднання одразу через TLS, інші порти -
+// звичайне зʼєднання з переходом на TLS (STARTTLS), якщо сервер це підтримує
+func sendEmail(config *SMTPConfig, subject, body string) error {
+	port := config.Port
+	if port == 0 {
+		port = 587 // Значення за замовчуванням
+	}
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: config.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	if port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return err
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildEmail(config, subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Формує лист з заголовками. Тема кодується, бо містить кирилицю
+func buildEmail(config *SMTPConfig, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// APIEndpoint - одне з джерел даних. Якщо auth_header не вказано, використовується загальний.
+// request_timeout_sec замінює http_timeout_sec для цього джерела
+type APIEndpoint struct {
+	URL               string `json:"url"`
+	AuthHeader        string `json:"auth_header"`
+	RequestTimeoutSec int    `json:"request_timeout_sec"`
+}
+
+// Дозволяє вказувати джерело як рядок з адресою або як обʼєкт з окремим заголовком авторизації
+func (e *APIEndpoint) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		e.URL = url
+		return nil
+	}
+	type plain APIEndpoint
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// Індекс джерела, яке відповіло останнім. Наступний запит починається з нього
+var activeEndpoint atomic.Int64
+
+// Повертає джерела у порядку опитування: api_urls, а якщо їх не вказано - api_url
+func apiEndpoints(config *Config) []APIEndpoint {
+	if len(config.APIURLs) == 0 {
+		return []APIEndpoint{{URL: config.APIURL, AuthHeader: config.AuthHeader}}
+	}
+	endpoints := make([]APIEndpoint, len(config.APIURLs))
+	for i, endpoint := range config.APIURLs {
+		if endpoint.AuthHeader == "" {
+			endpoint.AuthHeader = config.AuthHeader
+		}
+		endpoints[i] = endpoint
+	}
+	return endpoints
+}
+
+// Повертає граничний час запиту до джерела
+func endpointTimeout(config *Config, endpoint APIEndpoint) time.Duration {
+	if endpoint.RequestTimeoutSec > 0 {
+		return time.Duration(endpoint.RequestTimeoutSec) * time.Second
+	}
+	return requestTimeout(config)
+}
+
+// endpointHealthRegistry зберігає стан кожного джерела окремо
+type endpointHealthRegistry struct {
+	mu      sync.Mutex
+	sources map[string]*SourceHealth
+}
+
+var endpointHealth = &endpointHealthRegistry{sources: make(map[string]*SourceHealth)}
+
+// Враховує результат запиту до джерела. Про збої та відновлення пише у лог лише verbose,
+// щоб для єдиного джерела не дублювати повідомлення загального стану
+func (r *endpointHealthRegistry) record(url string, latency time.Duration, err error, verbose bool) SourceHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health, ok := r.sources[url]
+	if !ok {
+		health = &SourceHealth{URL: url}
+		r.sources[url] = health
+	}
+	if verbose {
+		health.record(latency, err)
+	} else {
+		health.update(latency, err)
+	}
+	recordSourceMetrics(*health)
+	return *health
+}
+
+// Повертає копію стану налаштованих джерел у порядку опитування
+func (r *endpointHealthRegistry) snapshot(config *Config) []SourceHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sources []SourceHealth
+	for _, endpoint := range apiEndpoints(config) {
+		if health, ok := r.sources[endpoint.URL]; ok {
+			sources = append(sources, *health)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Повільне джерело обмежене власним request_timeout_sec і не затримує наступні опитування
+func TestFetchAlertsSlowAndFastSource(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"regionName": "Київ", "lastUpdate": "2024-05-01T10:00:00Z", "activeAlerts": [{"type": "AIR", "lastUpdate": "2024-05-01T10:00:00Z"}]}]`))
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	config := &Config{
+		HTTPTimeoutSec: 30,
+		APIURLs: []APIEndpoint{
+			{URL: slow.URL, RequestTimeoutSec: 1},
+			{URL: fast.URL},
+		},
+	}
+	activeEndpoint.Store(0)
+	defer activeEndpoint.Store(0)
+
+	started := time.Now()
+	alerts, _, err := fetchAlerts(context.Background(), http.DefaultClient, config)
+	if err != nil {
+		t.Fatalf("fetchAlerts: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Type != "AIR" {
+		t.Fatalf("alerts = %+v, want one AIR alert", alerts)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("first poll took %s, slow source should time out after 1s", elapsed)
+	}
+
+	sources := sourceStatuses(config)
+	if len(sources) != 2 {
+		t.Fatalf("sources = %+v, want both endpoints", sources)
+	}
+	if sources[0].URL != slow.URL || sources[0].ConsecutiveFailures != 1 || sources[0].LastError == "" || sources[0].LastErrorAt.IsZero() {
+		t.Errorf("slow source status = %+v, want one recorded failure", sources[0])
+	}
+	if sources[1].URL != fast.URL || sources[1].ConsecutiveFailures != 0 || sources[1].LastSuccess.IsZero() {
+		t.Errorf("fast source status = %+v, want success", sources[1])
+	}
+
+	// Наступний запит починається зі справного джерела
+	started = time.Now()
+	if _, _, err := fetchAlerts(context.Background(), http.DefaultClient, config); err != nil {
+		t.Fatalf("second fetchAlerts: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("second poll took %s, want the fast source first", elapsed)
+	}
+	if failures := sourceStatuses(config)[0].ConsecutiveFailures; failures != 1 {
+		t.Errorf("slow source failures = %d after second poll, want 1", failures)
+	}
+}
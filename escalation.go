@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// EscalationStep - поріг, після якого повторний сигнал змінюється на інший звук
+type EscalationStep struct {
+	AfterMin  int    `json:"after_min"`
+	AudioFile string `json:"audio_file"`
+}
+
+// Escalation - пороги ескалації повторного сигналу для типів подій
+type Escalation map[string][]EscalationStep
+
+// Файл повторного звуку з урахуванням тривалості події: звук найбільшого порогу after_min,
+// який вже минув. До першого порогу лунає звичайний повторний сигнал
+func repeatAudioAt(config *Config, alertType string, elapsed time.Duration) string {
+	file := repeatAudioFor(config, alertType)
+	reached := -1
+	for _, step := range config.Escalation[alertType] {
+		if step.AudioFile == "" || step.AfterMin <= reached {
+			continue
+		}
+		if elapsed >= time.Duration(step.AfterMin)*time.Minute {
+			file, reached = step.AudioFile, step.AfterMin
+		}
+	}
+	return file
+}
+
+// Типи подій для escalate_after_sec, якщо escalate_types не вказано
+var defaultEscalateTypes = []string{"AIR"}
+
+// Ескалація непідтверджених тривог: якщо за escalate_after_sec від появи тривоги користувач
+// не підтвердив її через snooze_file, лунає escalate_audio_file і надсилається escalate_webhook_url.
+// Ескалація повторюється з тим самим інтервалом, доки тривогу не підтверджено або вона не закінчилась
+func (e *Evaluator) checkUnacknowledged(state *State, config *Config, now time.Time) []AlertEvent {
+	if config.EscalateAfterSec <= 0 {
+		return nil
+	}
+	if e.escalated == nil {
+		e.escalated = make(map[string]time.Time)
+	}
+	for alertType := range e.escalated {
+		if !state.ActiveAlertTypes[alertType] {
+			delete(e.escalated, alertType)
+		}
+	}
+
+	types := config.EscalateTypes
+	if len(types) == 0 {
+		types = defaultEscalateTypes
+	}
+	window := time.Duration(config.EscalateAfterSec) * time.Second
+	var events []AlertEvent
+	for _, alertType := range activeAlertTypes(state) {
+		if !slices.Contains(types, alertType) {
+			continue
+		}
+		// Відлік від появи тривоги у відповідях, а не від її початку на сервері
+		since, ok := state.SeenSince[alertType]
+		if !ok {
+			since, ok = state.ActiveSince[alertType]
+		}
+		if !ok {
+			continue
+		}
+		if acknowledgedAt, ok := snoozeTouchedAt(config); ok && !acknowledgedAt.Before(since) {
+			delete(e.escalated, alertType)
+			continue
+		}
+		if last, ok := e.escalated[alertType]; ok {
+			since = last
+		}
+		if now.Sub(since) < window {
+			continue
+		}
+		e.escalated[alertType] = now
+		logEvent(slog.LevelWarn, fmt.Sprintf("УВАГА: тривогу %s не підтверджено %s, ескалація", alertType, now.Sub(state.SeenSince[alertType]).Round(time.Second)),
+			"event", "escalate", "alert_type", alertType)
+		events = append(events, AlertEvent{Kind: "escalate", AlertType: alertType, Time: state.LastUpdate, Active: activeAlertTypes(state)})
+	}
+	return events
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Ставить час зміни snooze_file, тобто час підтвердження тривоги
+func touchSnooze(t *testing.T, path string, at time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEscalateUnacknowledged(t *testing.T) {
+	now := time.Now().UTC()
+	started := now.Add(-time.Minute).Format(time.RFC3339)
+	active := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+
+	newEvaluator := func(t *testing.T) (*Evaluator, string) {
+		snoozeFile := filepath.Join(t.TempDir(), "snooze")
+		e := newTestEvaluator(t, &Config{EscalateAfterSec: 300, SnoozeFile: snoozeFile})
+		if got := eventKinds(e.process(active)); !slices.Equal(got, []string{"start:AIR"}) {
+			t.Fatalf("first poll events = %v, want [start:AIR]", got)
+		}
+		// Тривога зʼявилась у відповідях 6 хвилин тому
+		e.state.SeenSince["AIR"] = now.Add(-6 * time.Minute)
+		return e, snoozeFile
+	}
+
+	t.Run("acknowledged before the window", func(t *testing.T) {
+		e, snoozeFile := newEvaluator(t)
+		touchSnooze(t, snoozeFile, now.Add(-2*time.Minute))
+		if got := eventKinds(e.process(active)); len(got) != 0 {
+			t.Errorf("events = %v, want no escalation", got)
+		}
+	})
+
+	t.Run("snooze from before the alert is not an acknowledgement", func(t *testing.T) {
+		e, snoozeFile := newEvaluator(t)
+		touchSnooze(t, snoozeFile, now.Add(-10*time.Minute))
+		if got := eventKinds(e.process(active)); !slices.Equal(got, []string{"escalate:AIR"}) {
+			t.Errorf("events = %v, want [escalate:AIR]", got)
+		}
+	})
+
+	t.Run("acknowledged after escalation", func(t *testing.T) {
+		e, snoozeFile := newEvaluator(t)
+		if got := eventKinds(e.process(active)); !slices.Equal(got, []string{"escalate:AIR"}) {
+			t.Fatalf("events = %v, want [escalate:AIR]", got)
+		}
+		// До наступного інтервалу ескалація не повторюється
+		if got := eventKinds(e.process(active)); len(got) != 0 {
+			t.Errorf("events right after escalation = %v, want none", got)
+		}
+
+		// Без підтвердження ескалація повторюється через escalate_after_sec
+		e.escalated["AIR"] = now.Add(-6 * time.Minute)
+		if got := eventKinds(e.process(active)); !slices.Equal(got, []string{"escalate:AIR"}) {
+			t.Errorf("events after another window = %v, want [escalate:AIR]", got)
+		}
+
+		// Підтвердження зупиняє ескалацію
+		touchSnooze(t, snoozeFile, time.Now())
+		e.escalated["AIR"] = now.Add(-6 * time.Minute)
+		if got := eventKinds(e.process(active)); len(got) != 0 {
+			t.Errorf("events after acknowledgement = %v, want none", got)
+		}
+		if _, ok := e.escalated["AIR"]; ok {
+			t.Error("escalation not reset after acknowledgement")
+		}
+	})
+
+	t.Run("types outside escalate_types", func(t *testing.T) {
+		e, _ := newEvaluator(t)
+		e.config.EscalateTypes = []string{"BALLISTIC"}
+		if got := eventKinds(e.process(active)); len(got) != 0 {
+			t.Errorf("events = %v, want no escalation", got)
+		}
+	})
+}
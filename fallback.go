@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+// Кількість помилок поспіль, після якої використовується резервний файл (за замовчуванням)
+const defaultFallbackAfterFailures = 3
+
+// Читає резервний файл тривог. Кожен непорожній рядок - тип активної тривоги,
+// рядки, що починаються з # - коментарі. Порожній файл означає відсутність тривог.
+// Часом оновлення вважається час зміни файлу
+func readFallbackAlerts(path string) ([]Alert, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	lastUpdate := info.ModTime().UTC().Format(time.RFC3339)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var alerts []Alert
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		alerts = append(alerts, Alert{Type: line, LastUpdate: lastUpdate})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+	return alerts, lastUpdate, nil
+}
+
+// Чи слід використати резервний файл замість мережевого джерела
+func shouldUseFallback(config *Config, health *SourceHealth) bool {
+	if config.FallbackAlertFile == "" {
+		return false
+	}
+	threshold := config.FallbackAfterFails
+	if threshold <= 0 {
+		threshold = defaultFallbackAfterFailures
+	}
+	return health.ConsecutiveFailures >= threshold
+}
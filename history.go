@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Заголовок файлу історії тривог
+var historyHeader = []string{"timestamp", "alert_type", "event", "duration_sec"}
+
+// Дописує у history_csv_path рядки про початок і закінчення тривог.
+// Для закінчення вказується тривалість у секундах, якщо час початку відомий
+func appendHistory(config *Config, location *time.Location, events []AlertEvent, now time.Time) {
+	if config.HistoryCSVPath == "" {
+		return
+	}
+
+	var rows [][]string
+	timestamp := now.In(location).Format("2006-01-02 15:04:05")
+	for _, event := range events {
+		switch event.Kind {
+		case "start":
+			rows = append(rows, []string{timestamp, event.AlertType, "start", ""})
+		case "end":
+			duration := ""
+			if event.Duration > 0 {
+				duration = strconv.FormatInt(int64(event.Duration.Seconds()), 10)
+			}
+			rows = append(rows, []string{timestamp, event.AlertType, "end", duration})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	file, err := os.OpenFile(config.HistoryCSVPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logError("Помилка відкриття файлу історії: %v", err)
+		return
+	}
+	defer file.Close()
+
+	// Заголовок пишемо лише у новий файл
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		rows = append([][]string{historyHeader}, rows...)
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(rows); err != nil {
+		logError("Помилка запису у файл історії: %v", err)
+	}
+}
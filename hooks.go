@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Час виконання зовнішньої команди за замовчуванням
+const defaultHookTimeout = 30 * time.Second
+
+// Запускає зовнішню команду для події початку або закінчення тривоги.
+// Команда виконується у окремій горутині і не впливає на основний цикл
+func runHook(config *Config, event AlertEvent) {
+	var command string
+	switch event.Kind {
+	case "start":
+		command = config.OnAlertStartCmd
+	case "end":
+		command = config.OnAlertEndCmd
+	}
+	// Команда виконується без оболонки, тому значення подій не інтерпретуються
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return
+	}
+
+	timeout := time.Duration(config.HookTimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, args[0], append(args[1:], event.Kind, event.AlertType, event.Time)...)
+		cmd.Env = append(os.Environ(),
+			"SIGNAL_EVENT="+event.Kind,
+			"SIGNAL_ALERT_TYPE="+event.AlertType,
+			"SIGNAL_TIME="+event.Time,
+			"SIGNAL_LOCAL_TIME="+convertToLocalTime(event.Time, config.TimeZone),
+			"SIGNAL_REGION="+event.Region,
+			"SIGNAL_API_URL="+config.APIURL,
+		)
+
+		output, err := cmd.CombinedOutput()
+		if len(output) > 0 {
+			log.Printf("Вивід команди %s: %s", args[0], strings.TrimSpace(string(output)))
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("Команда %s перевищила час виконання %s", args[0], timeout)
+		} else if err != nil {
+			logError("Помилка виконання команди %s: %v", args[0], err)
+		}
+	}()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Параметри вимірювання затримки аудіо
+const (
+	latencySampleRate = beep.SampleRate(44100)
+	latencyRuns       = 5
+	latencyMarkerHz   = 1000.0
+	latencyMarkerLen  = 50 * time.Millisecond
+	latencyTarget     = 250 * time.Millisecond
+)
+
+// Короткий сигнал-маркер, який повідомляє момент, коли динамік вперше забирає з нього дані
+func latencyMarker(started chan<- time.Time) beep.Streamer {
+	total := latencySampleRate.N(latencyMarkerLen)
+	pos := 0
+	return beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		if pos == 0 {
+			select {
+			case started <- time.Now():
+			default:
+			}
+		}
+		if pos >= total {
+			return 0, false
+		}
+		for i := range samples {
+			if pos >= total {
+				return i, true
+			}
+			value := 0.5 * math.Sin(2*math.Pi*latencyMarkerHz*float64(pos)/float64(latencySampleRate))
+			samples[i][0], samples[i][1] = value, value
+			pos++
+		}
+		return len(samples), true
+	})
+}
+
+func measureAudioLatency(config *Config) {
+	buffer := audioBufferDuration(config)
+	fmt.Printf("Розмір буфера: %s (%d семплів при %d Гц)\n", buffer, latencySampleRate.N(buffer), latencySampleRate)
+
+	started := time.Now()
+	if err := initSpeaker(config, latencySampleRate); err != nil {
+		fmt.Printf("Помилка ініціалізації аудіо: %v\n", err)
+		return
+	}
+	initTime := time.Since(started)
+	fmt.Printf("Ініціалізація динаміка: %s\n", initTime)
+
+	var total, worst time.Duration
+	best := time.Duration(math.MaxInt64)
+	for i := 0; i < latencyRuns; i++ {
+		firstPull := make(chan time.Time, 1)
+		decided := time.Now()
+		speaker.Play(latencyMarker(firstPull))
+
+		var latency time.Duration
+		select {
+		case pulled := <-firstPull:
+			latency = pulled.Sub(decided)
+		case <-time.After(time.Second + 2*buffer):
+			fmt.Println("Динамік не відтворює звук, вимірювання перервано")
+			return
+		}
+		fmt.Printf("Вимірювання %d: %s\n", i+1, latency)
+
+		total += latency
+		worst = max(worst, latency)
+		best = min(best, latency)
+		time.Sleep(latencyMarkerLen + buffer)
+	}
+
+	average := total / latencyRuns
+	estimated := initTime + average + buffer
+	fmt.Printf("Затримка до початку відтворення: середня %s, мінімальна %s, максимальна %s\n", average, best, worst)
+	fmt.Printf("Орієнтовна затримка від рішення до звуку (ініціалізація + очікування + буфер): %s\n", estimated)
+
+	// Рекомендація щодо audio_buffer_ms
+	switch {
+	case estimated > latencyTarget && buffer > 20*time.Millisecond:
+		fmt.Printf("Рекомендація: затримка велика, спробуйте зменшити audio_buffer_ms до %d\n", max(20, buffer.Milliseconds()/2))
+	case worst-best > buffer/2:
+		fmt.Printf("Рекомендація: затримка нестабільна, можливі перебої звуку. Спробуйте збільшити audio_buffer_ms до %d\n", buffer.Milliseconds()*2)
+	default:
+		fmt.Println("Рекомендація: поточні налаштування підходять. Якщо чути тріск або перебої, збільште audio_buffer_ms")
+	}
+}
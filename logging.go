@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Увімкнено запис логу у форматі JSON (log_format: json)
+var jsonLogs bool
+
+// Місця запису текстового логу, заповнюється у setupLogging
+var logOut *logOutput
+
+// Перенаправляє стандартний лог у JSON обробник: кожен рядок - окремий JSON обʼєкт
+// з полями ts, level, msg, а також instance та run_id замість префікса
+func setupJSONLogging(out *logOutput, config *Config) {
+	log.SetPrefix("")
+	slog.SetDefault(slog.New(out.jsonHandler()).With("instance", config.InstanceName, "run_id", runID))
+	jsonLogs = true
+}
+
+// Записує ключову подію. У форматі JSON додаткові поля (alert_type, last_update, ...)
+// записуються окремо, у текстовому форматі рядок лишається без змін
+func logEvent(level slog.Level, text string, fields ...any) {
+	if jsonLogs {
+		slog.Log(context.Background(), level, text, fields...)
+		return
+	}
+	if logOut == nil {
+		log.Print(text) // Логування ще не налаштовано
+		return
+	}
+	logOut.logger(level).Print(text)
+}
+
+// Рядок рівня debug, для повідомлень з debug: true
+func logDebug(format string, args ...any) {
+	logEvent(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+// Рядок рівня warn: збій, після якого програма продовжує роботу
+func logWarn(format string, args ...any) {
+	logEvent(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// Рядок рівня error
+func logError(format string, args ...any) {
+	logEvent(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// Записує помилку і завершує програму, як log.Fatalf
+func logFatal(format string, args ...any) {
+	logError(format, args...)
+	os.Exit(1)
+}
+
+// Повертає мінімальний рівень рядків для stdout_log_level та file_log_level. Порожнє значення - всі рядки
+func parseLogLevel(name string) (slog.Level, error) {
+	if name == "" {
+		return slog.LevelDebug, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("невідомий рівень логу %q, можливі значення: debug, info, warn, error", name)
+	}
+	return level, nil
+}
+
+// logSink - місце запису логу з мінімальним рівнем рядків
+type logSink struct {
+	out   io.Writer
+	level slog.Level
+}
+
+// logOutput записує кожен рядок лише у ті місця, рівень яких не вищий за рівень рядка.
+// Рівень рядка задає виклик логування (logDebug, logWarn, ...), звичайний log.Printf - info
+type logOutput struct {
+	mu    sync.Mutex
+	sinks []logSink
+}
+
+func (o *logOutput) write(level slog.Level, line []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, sink := range o.sinks {
+		if level < sink.level {
+			continue
+		}
+		if _, err := sink.out.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Текстовий логер рядків заданого рівня з префіксом і прапорцями стандартного логу
+func (o *logOutput) logger(level slog.Level) *log.Logger {
+	return log.New(leveledWriter{out: o, level: level}, log.Prefix(), log.Flags())
+}
+
+// JSON обробник, що передає запис кожному місцю, рівень якого його дозволяє
+func (o *logOutput) jsonHandler() slog.Handler {
+	handlers := make(fanoutHandler, 0, len(o.sinks))
+	for _, sink := range o.sinks {
+		handlers = append(handlers, slog.NewJSONHandler(sink.out, &slog.HandlerOptions{
+			Level: sink.level,
+			ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+				if len(groups) == 0 && attr.Key == slog.TimeKey {
+					attr.Key = "ts"
+				}
+				return attr
+			},
+		}))
+	}
+	return handlers
+}
+
+// leveledWriter - io.Writer для рядків одного рівня
+type leveledWriter struct {
+	out   *logOutput
+	level slog.Level
+}
+
+func (w leveledWriter) Write(line []byte) (int, error) {
+	if err := w.out.write(w.level, line); err != nil {
+		return 0, err
+	}
+	return len(line), nil
+}
+
+// fanoutHandler передає запис усім обробникам, що його дозволяють
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
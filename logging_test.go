@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogOutputSinks(t *testing.T) {
+	var stdout, file bytes.Buffer
+	out := &logOutput{sinks: []logSink{
+		{out: &stdout, level: slog.LevelWarn},
+		{out: &file, level: slog.LevelDebug},
+	}}
+
+	// Текстовий формат: рівень задає логер, текст рядка на нього не впливає
+	out.logger(slog.LevelDebug).Print("Запит до сервера тривав 120ms")
+	out.logger(slog.LevelWarn).Print("Попередження: повільний запит")
+	out.logger(slog.LevelError).Print("Джерело не відповідає")
+	log.New(leveledWriter{out: out, level: slog.LevelInfo}, "", 0).Print("Помилка у тексті рядка рівня info")
+
+	// JSON формат: кожне місце отримує записи свого рівня
+	jsonLogger := slog.New(out.jsonHandler())
+	jsonLogger.Debug("Дані не змінились з попереднього запиту")
+	jsonLogger.Info("Тривога увімкнено: AIR")
+	jsonLogger.Warn("Тривога триває довше 6 годин")
+	jsonLogger.Error("Помилка збереження стану")
+
+	wantStdout := []string{
+		"Попередження: повільний запит",
+		"Джерело не відповідає",
+		`"level":"WARN","msg":"Тривога триває довше 6 годин"`,
+		`"level":"ERROR","msg":"Помилка збереження стану"`,
+	}
+	wantFile := []string{
+		"Запит до сервера тривав 120ms",
+		"Попередження: повільний запит",
+		"Джерело не відповідає",
+		"Помилка у тексті рядка рівня info",
+		`"level":"DEBUG","msg":"Дані не змінились з попереднього запиту"`,
+		`"level":"INFO","msg":"Тривога увімкнено: AIR"`,
+		`"level":"WARN","msg":"Тривога триває довше 6 годин"`,
+		`"level":"ERROR","msg":"Помилка збереження стану"`,
+	}
+	for name, tt := range map[string]struct {
+		got  string
+		want []string
+	}{"stdout": {stdout.String(), wantStdout}, "file": {file.String(), wantFile}} {
+		lines := strings.Split(strings.TrimSuffix(tt.got, "\n"), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s got %d lines, want %d:\n%s", name, len(lines), len(tt.want), tt.got)
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("%s line %d = %q, want it to contain %q", name, i, lines[i], want)
+			}
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelDebug, false},
+		{"debug", slog.LevelDebug, false},
+		{"info", slog.LevelInfo, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLogLevel(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLogLevel(%q) = %v, %v; want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Файл логу з ротацією за розміром. Безпечний для одночасного запису
+type rotatingWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingWriter(path string, maxSizeMB, maxBackups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize && w.size > 0 {
+		if err := w.rotate(); err != nil {
+			// Не вдалося перейменувати файл - продовжуємо писати у поточний
+			fmt.Fprintf(os.Stderr, "Помилка ротації файлу логу: %v\n", err)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Зсуває резервні копії: log.1 -> log.2, ..., поточний файл стає log.1.
+// Найстаріша копія понад log_max_backups видаляється
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	if w.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxBackups))
+		for i := w.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return w.reopen(err)
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return w.reopen(err)
+	}
+
+	return w.open()
+}
+
+// Знову відкриває поточний файл після невдалої ротації
+func (w *rotatingWriter) reopen(cause error) error {
+	if err := w.open(); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
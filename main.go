@@ -1,51 +1,357 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"maps"
+	"math"
+	mathrand "math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
 	"github.com/faiface/beep/speaker"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
 )
 
 type Config struct {
-	APIURL             string            `json:"api_url"`
-	AuthHeader         string            `json:"auth_header"`
-	AudioFiles         map[string]string `json:"audio_files"`
-	AlertOnEmpty       string            `json:"alert_on_empty"`
-	Debug              bool              `json:"debug"`
-	LogToFile          bool              `json:"log_to_file"`
-	LogFilePath        string            `json:"log_file_path"`
-	TimeZone           string            `json:"time_zone"`
-	RepeatAudioFile    string            `json:"repeat_audio_file"`
-	RepeatIntervalMin  int               `json:"repeat_interval_min"`
-	RequestIntervalSec int               `json:"request_interval_sec"`
-	EnableRepeatAudio  bool              `json:"enable_repeat_audio"` // Додано поле для керування повторюваним сигналом
+	APIURL             string              `json:"api_url"`
+	AuthHeader         string              `json:"auth_header"`
+	AudioFiles         map[string]Playlist `json:"audio_files"`
+	AlertOnEmpty       string              `json:"alert_on_empty"`
+	Debug              bool                `json:"debug"`
+	LogToFile          bool                `json:"log_to_file"`
+	LogFilePath        string              `json:"log_file_path"`
+	TimeZone           string              `json:"time_zone"`
+	RepeatAudioFile    string              `json:"repeat_audio_file"`
+	RepeatIntervalMin  int                 `json:"repeat_interval_min"`
+	RequestIntervalSec int                 `json:"request_interval_sec"`
+	EnableRepeatAudio  bool                `json:"enable_repeat_audio"` // Додано поле для керування повторюваним сигналом
+	EnableAttention    bool                `json:"enable_attention_tone"`
+	AttentionTone      string              `json:"attention_tone"`
+	AttentionOnRepeat  bool                `json:"attention_on_repeat"`
+	AttentionOnClear   bool                `json:"attention_on_clear"`
+	EnableCache        bool                `json:"enable_response_cache"`
+	CacheTTLSec        int                 `json:"response_cache_ttl_sec"`
+	AcceptHeader       string              `json:"accept_header"`
+	APIFormat          string              `json:"api_format"`
+	CAPEventCode       string              `json:"cap_event_code"`
+	CAPAreaFilter      string              `json:"cap_area_filter"`
+	SyslogEnabled      bool                `json:"syslog_enabled"`
+	SyslogTag          string              `json:"syslog_tag"`
+	UISmoothingSec     int                 `json:"ui_smoothing_sec"`
+	AudioBufferMs      int                 `json:"audio_buffer_ms"`
+	HMACSecret         string              `json:"hmac_secret"`
+	HMACHeader         string              `json:"hmac_header"`
+	HMACTimeHeader     string              `json:"hmac_timestamp_header"`
+	HMACScheme         string              `json:"hmac_scheme"`
+	HMACMessage        string              `json:"hmac_message"`
+	HMACEncoding       string              `json:"hmac_encoding"`
+	RestartDedupMin    int                 `json:"restart_dedup_min"`
+	TypeGroups         map[string][]string `json:"type_groups"`
+	FallbackAlertFile  string              `json:"fallback_alert_file"`
+	FallbackAfterFails int                 `json:"fallback_after_failures"`
+	BooleanField       string              `json:"boolean_field"`
+	BooleanAlertType   string              `json:"boolean_alert_type"`
+	BooleanTimeField   string              `json:"boolean_time_field"`
+	InstanceName       string              `json:"instance_name"`
+	MaxAlertAgeMin     int                 `json:"max_alert_age_min"`
+	ImmediateTypes     []string            `json:"immediate_types"`
+	StillActiveChime   string              `json:"still_active_chime"`
+	StillActiveMin     int                 `json:"still_active_interval_min"`
+	RegionNameFilter   string              `json:"region_name_filter"`
+	StartupLastPlayed  string              `json:"startup_last_played"`
+	DailySummaryAt     string              `json:"daily_summary_at"`
+	DailySummaryMissed string              `json:"daily_summary_missed"`
+	AudioRetries       int                 `json:"audio_retries"`
+	AudioRetryDelayMs  int                 `json:"audio_retry_delay_ms"`
+	OnAlertStartCmd    string              `json:"on_alert_start_cmd"`
+	OnAlertEndCmd      string              `json:"on_alert_end_cmd"`
+	HookTimeoutSec     int                 `json:"hook_timeout_sec"`
+	UseServerPriority  bool                `json:"use_server_priority"`
+	EnableDeescalation bool                `json:"enable_deescalation"`
+	DeescalationAudio  string              `json:"deescalation_audio"`
+	MaxRuntimeSec      int                 `json:"max_runtime_sec"`
+	HTTPTimeoutSec     int                 `json:"http_timeout_sec"`
+	Regions            []RegionRef         `json:"regions"`
+	AudioSampleRate    int                 `json:"audio_sample_rate"`
+	MaxBackoffSec      int                 `json:"max_backoff_sec"`
+	RepeatIntervals    map[string]int      `json:"repeat_intervals_min"`
+	RepeatAudioFiles   map[string]string   `json:"repeat_audio_files"`
+	QuietHours         *QuietHours         `json:"quiet_hours"`
+	QuietMode          string              `json:"quiet_mode"`
+	AlertPriority      []string            `json:"alert_priority"`
+	TelegramBotToken   string              `json:"telegram_bot_token"`
+	TelegramChatID     string              `json:"telegram_chat_id"`
+	StatusListenAddr   string              `json:"status_listen_addr"`
+	Volume             *float64            `json:"volume"`
+	AudioGains         map[string]float64  `json:"audio_gains"`
+	ActiveIntervalSec  int                 `json:"active_request_interval_sec"`
+	APIURLs            []APIEndpoint       `json:"api_urls"`
+	LogFormat          string              `json:"log_format"`
+	StdoutLogLevel     string              `json:"stdout_log_level"`
+	FileLogLevel       string              `json:"file_log_level"`
+	LogMaxSizeMB       int                 `json:"log_max_size_mb"`
+	LogMaxBackups      int                 `json:"log_max_backups"`
+	HistoryCSVPath     string              `json:"history_csv_path"`
+	AlertOffCooldown   int                 `json:"alert_off_cooldown_sec"`
+	DesktopNotify      bool                `json:"desktop_notifications"`
+	WebhookURL         string              `json:"webhook_url"`
+	WebhookHeaders     map[string]string   `json:"webhook_headers"`
+	MetricsListenAddr  string              `json:"metrics_listen_addr"`
+	TTS                *TTSConfig          `json:"tts"`
+	AnnounceTime       bool                `json:"announce_time"`
+	TypeLabels         map[string]string   `json:"type_labels"` // Назви типів подій для оголошення
+	AudioWhitelist     []string            `json:"audio_types_whitelist"`
+	AudioBlacklist     []string            `json:"audio_types_blacklist"`
+	SourceType         string              `json:"source_type"`
+	SourceFile         string              `json:"source_file"`
+	MQTTBroker         string              `json:"mqtt_broker"`
+	MQTTTopic          string              `json:"mqtt_topic"`
+	MQTTUsername       string              `json:"mqtt_username"`
+	MQTTPassword       string              `json:"mqtt_password"`
+	MQTTHADiscovery    bool                `json:"mqtt_ha_discovery"`
+	MQTTHAPrefix       string              `json:"mqtt_ha_prefix"`
+	MQTTHACleanup      bool                `json:"mqtt_ha_cleanup"`
+	ProxyURL           string              `json:"proxy_url"`
+	SnoozeFile         string              `json:"snooze_file"`
+	SnoozeMin          int                 `json:"snooze_min"`
+	SnoozeSkipAllClear bool                `json:"snooze_skip_all_clear"`
+	EscalateAfterSec   int                 `json:"escalate_after_sec"`
+	EscalateTypes      []string            `json:"escalate_types"`
+	EscalateAudioFile  string              `json:"escalate_audio_file"`
+	EscalateWebhookURL string              `json:"escalate_webhook_url"`
+	AudioDevice        string              `json:"audio_device"`
+	Escalation         Escalation          `json:"escalation"`
+	RequestJitterSec   int                 `json:"request_jitter_sec"`
+	SMTP               *SMTPConfig         `json:"smtp"`
+	AllowNoAudio       bool                `json:"allow_no_audio"`
+	HTTPMethod         string              `json:"http_method"`
+	RequestBody        string              `json:"request_body"`
+	StaleDataMin       int                 `json:"stale_data_min"`
+	StaleDataNotify    bool                `json:"stale_data_notify"`
+	PIDFile            string              `json:"pid_file"`
+	TransitionAudio    map[string]string   `json:"transition_audio"`
+	AudioQueueDedupe   bool                `json:"audio_queue_dedupe"`
+	SlowRequestMs      int                 `json:"slow_request_ms"`
+	AllClearTypes      []string            `json:"all_clear_types"`
+	InsecureSkipVerify bool                `json:"insecure_skip_verify"`
+
+	// Скомпільований region_name_filter, заповнюється у validateConfig
+	regionNamePattern *regexp.Regexp
 }
 
 type Region struct {
-	LastUpdate   string  `json:"lastUpdate"`
-	ActiveAlerts []Alert `json:"activeAlerts"`
+	RegionID      string  `json:"regionId"`
+	RegionName    string  `json:"regionName"`
+	RegionEngName string  `json:"regionEngName"`
+	LastUpdate    string  `json:"lastUpdate"`
+	ActiveAlerts  []Alert `json:"activeAlerts"`
 }
 
 type Alert struct {
 	Type       string `json:"type"`
 	LastUpdate string `json:"lastUpdate"`
+	Level      *int   `json:"level,omitempty"` // Рівень важливості від провайдера, якщо є
+	// Назва регіону, з якого надійшла подія
+	Region string `json:"region,omitempty"`
+}
+
+// Розбирає кожну подію регіону окремо: некоректні події та події без типу пропускаються
+// із записом у лог, щоб один зіпсований запис не зупиняв обробку всієї відповіді
+func (r *Region) UnmarshalJSON(data []byte) error {
+	type plainRegion Region
+	var raw struct {
+		plainRegion
+		ActiveAlerts []json.RawMessage `json:"activeAlerts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = Region(raw.plainRegion)
+	r.ActiveAlerts = nil
+	for _, item := range raw.ActiveAlerts {
+		var alert Alert
+		if err := json.Unmarshal(item, &alert); err != nil {
+			logWarn("Пропущено некоректну подію регіону %s: %v, запис: %.200s", r.RegionName, err, item)
+			continue
+		}
+		if alert.Type == "" {
+			log.Printf("Пропущено подію регіону %s без типу, запис: %.200s", r.RegionName, item)
+			continue
+		}
+		if alert.Region == "" {
+			alert.Region = r.RegionName
+		}
+		r.ActiveAlerts = append(r.ActiveAlerts, alert)
+	}
+	return nil
 }
 
 type State struct {
-	ActiveAlertTypes map[string]bool      `json:"active_alert_types"`
-	LastUpdate       string               `json:"last_update"`
-	LastPlayed       map[string]time.Time `json:"last_played"`
+	mu sync.RWMutex // Захищає стан від одночасного доступу з різних горутин
+
+	Version          int                    `json:"version"`
+	ActiveAlertTypes map[string]bool        `json:"active_alert_types"`
+	LastUpdate       string                 `json:"last_update"`
+	LastPlayed       map[string]time.Time   `json:"last_played"`
+	Cache            *ResponseCache         `json:"cache,omitempty"`
+	SeenSince        map[string]time.Time   `json:"-"` // З якого часу подія безперервно присутня у відповідях (лише для відображення)
+	ResponseHash     string                 `json:"response_hash,omitempty"`
+	ProcessedAt      time.Time              `json:"processed_at,omitempty"`
+	LastChime        map[string]time.Time   `json:"last_chime,omitempty"`
+	ActiveSince      map[string]time.Time   `json:"active_since,omitempty"` // Час початку активних подій
+	DailyStats       map[string]*AlertStats `json:"daily_stats,omitempty"`
+	SummarySentAt    time.Time              `json:"summary_sent_at,omitempty"`
+	LastFetchOK      time.Time              `json:"-"` // Час останнього успішного запиту (лише для перевірки стану)
+	PendingOff       map[string]time.Time   `json:"-"` // З якого часу активна подія відсутня у відповідях (alert_off_cooldown_sec)
+	Expired          map[string]bool        `json:"-"` // Активні події, застарілі за max_alert_age_min: повтори для них не лунають
+	// Регіон, з якого надійшла активна подія
+	AlertRegions map[string]string `json:"alert_regions,omitempty"`
+}
+
+// Snapshot повертає копію стану для читання з інших горутин
+func (s *State) Snapshot() *State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := &State{
+		Version:          s.Version,
+		ActiveAlertTypes: make(map[string]bool, len(s.ActiveAlertTypes)),
+		LastUpdate:       s.LastUpdate,
+		LastPlayed:       make(map[string]time.Time, len(s.LastPlayed)),
+		SeenSince:        make(map[string]time.Time, len(s.SeenSince)),
+		PendingOff:       make(map[string]time.Time, len(s.PendingOff)),
+		Expired:          make(map[string]bool, len(s.Expired)),
+		LastChime:        make(map[string]time.Time, len(s.LastChime)),
+		ActiveSince:      make(map[string]time.Time, len(s.ActiveSince)),
+		DailyStats:       make(map[string]*AlertStats, len(s.DailyStats)),
+		AlertRegions:     make(map[string]string, len(s.AlertRegions)),
+		SummarySentAt:    s.SummarySentAt,
+		ResponseHash:     s.ResponseHash,
+		ProcessedAt:      s.ProcessedAt,
+		LastFetchOK:      s.LastFetchOK,
+	}
+	for alertType, active := range s.ActiveAlertTypes {
+		snapshot.ActiveAlertTypes[alertType] = active
+	}
+	for alertType, played := range s.LastPlayed {
+		snapshot.LastPlayed[alertType] = played
+	}
+	for alertType, since := range s.SeenSince {
+		snapshot.SeenSince[alertType] = since
+	}
+	for alertType, since := range s.PendingOff {
+		snapshot.PendingOff[alertType] = since
+	}
+	for alertType, expired := range s.Expired {
+		snapshot.Expired[alertType] = expired
+	}
+	for alertType, chimed := range s.LastChime {
+		snapshot.LastChime[alertType] = chimed
+	}
+	for alertType, since := range s.ActiveSince {
+		snapshot.ActiveSince[alertType] = since
+	}
+	for alertType, region := range s.AlertRegions {
+		snapshot.AlertRegions[alertType] = region
+	}
+	for alertType, stats := range s.DailyStats {
+		copied := *stats
+		snapshot.DailyStats[alertType] = &copied
+	}
+	if s.Cache != nil {
+		cache := *s.Cache
+		cache.Alerts = append([]Alert(nil), s.Cache.Alerts...)
+		snapshot.Cache = &cache
+	}
+	return snapshot
+}
+
+// ResponseCache зберігає останню успішну відповідь сервера для відновлення після перезапуску
+type ResponseCache struct {
+	Alerts     []Alert   `json:"alerts"`
+	LastUpdate string    `json:"last_update"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// SourceHealth відстежує стан джерела даних
+type SourceHealth struct {
+	URL                 string
+	LastSuccess         time.Time
+	LastError           string
+	LastErrorAt         time.Time
+	Latency             time.Duration
+	ConsecutiveFailures int
+}
+
+// Кількість помилок поспіль, після якої джерело вважається несправним
+const sourceFailureThreshold = 5
+
+// Враховує результат запиту і пише у лог про тривалий збій та відновлення джерела
+func (h *SourceHealth) record(latency time.Duration, err error) {
+	failures := h.update(latency, err)
+	if err != nil {
+		if h.ConsecutiveFailures%sourceFailureThreshold == 0 {
+			logWarn("УВАГА: джерело %s не відповідає %d запитів поспіль, остання помилка: %s", h.URL, h.ConsecutiveFailures, h.LastError)
+		}
+		return
+	}
+	if failures >= sourceFailureThreshold {
+		log.Printf("Джерело %s знову доступне після %d помилок", h.URL, failures)
+	}
+}
+
+// Оновлює стан без запису у лог. Повертає кількість помилок поспіль до цього запиту
+func (h *SourceHealth) update(latency time.Duration, err error) int {
+	failures := h.ConsecutiveFailures
+	h.Latency = latency
+	if err != nil {
+		h.LastError = err.Error()
+		h.LastErrorAt = time.Now().UTC()
+		h.ConsecutiveFailures++
+		return failures
+	}
+	h.LastSuccess = time.Now().UTC()
+	h.ConsecutiveFailures = 0
+	return failures
+}
+
+// Ідентифікатор поточного запуску програми для кореляції логів
+var runID string
+
+func newRunID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(buf)
 }
 
 func main() {
@@ -54,8 +360,22 @@ func main() {
 	statePath := flag.String("state", "state.json", "Шлях до файлу стану")
 	help := flag.Bool("help", false, "Вивести інформацію про налаштування та вийти")
 	configDesc := flag.Bool("config-desc", false, "Вивести опис файлу конфігурації та вийти")
+	measureLatency := flag.Bool("measure-audio-latency", false, "Виміряти затримку відтворення аудіо та вийти")
+	configInit := flag.Bool("config-init", false, "Створити шаблон файлу налаштувань за шляхом -config та вийти")
+	force := flag.Bool("force", false, "Перезаписати наявний файл налаштувань разом з -config-init")
+	simulate := flag.String("simulate", "", "Відтворити послідовність відповідей з файлу замість запитів до сервера")
+	simulateInterval := flag.Duration("simulate-interval", 2*time.Second, "Пауза між відповідями у режимі симуляції")
+	testAudio := flag.Bool("test-audio", false, "Відтворити всі налаштовані звуки та вийти. Можна вказати тип події: -test-audio AIR")
+	allowInvalidAudio := flag.Bool("allow-invalid-audio", false, "Запускатися, навіть якщо деякі аудіофайли відсутні або пошкоджені")
+	showVersion := flag.Bool("version", false, "Вивести версію програми та вийти")
+	once := flag.Bool("once", false, "Виконати одну перевірку, зберегти стан і вийти. Код завершення: 0 - тривог немає, 10 - є активні тривоги, 1 - помилка")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
 	// Якщо вказано прапорець help, виводимо інформацію про налаштування
 	if *help {
 		fmt.Println("Програма для моніторингу подій та відтворення аудіо.")
@@ -70,30 +390,115 @@ func main() {
 		return
 	}
 
+	// Якщо вказано прапорець config-init, створюємо шаблон конфігурації
+	if *configInit {
+		if err := writeConfigTemplate(*configPath, *force); err != nil {
+			logFatal("Помилка створення файлу налаштувань: %v", err)
+		}
+		fmt.Printf("Створено файл налаштувань %s\n", *configPath)
+		return
+	}
+
 	// Завантажуємо конфігурацію
 	config, err := loadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Помилка завантаження конфігурації: %v", err)
+		logFatal("Помилка завантаження конфігурації: %v", err)
+	}
+	if err := validateConfig(config); err != nil {
+		logFatal("Помилки у конфігурації %s:\n%v", *configPath, err)
 	}
 
 	// Налаштовуємо логування
-	setupLogging(config)
+	runID = newRunID()
+	logFile := setupLogging(config)
+	if logFile != nil {
+		defer logFile.Close()
+	}
+	debugTimeParsing.Store(config.Debug)
+	setupSyslog(config)
+	log.Println(versionString())
+	log.Printf("Запуск: екземпляр %s, ідентифікатор запуску %s, джерело %s", config.InstanceName, runID, config.APIURL)
+
+	// Якщо вказано прапорець measure-audio-latency, вимірюємо затримку аудіо
+	if *measureLatency {
+		measureAudioLatency(config)
+		return
+	}
+
+	// Не даємо запустити другий екземпляр з тим самим pid_file. Перевірка звуку не блокується
+	if config.PIDFile != "" && !*testAudio {
+		if err := acquirePIDFile(config.PIDFile); err != nil {
+			logFatal("Помилка PID файлу: %v", err)
+		}
+		defer releasePIDFile(config.PIDFile)
+	}
+
+	// Перевіряємо аудіофайли заздалегідь, а не під час тривоги
+	if err := validateAudioFiles(config); err != nil {
+		if !*allowInvalidAudio {
+			logFatal("Помилка перевірки аудіофайлів:\n%v\nВиправте шляхи у конфігурації або запустіть з прапорцем -allow-invalid-audio", err)
+		}
+		logWarn("УВАГА! Помилка перевірки аудіофайлів, ці звуки не лунатимуть:\n%v", err)
+	}
+
+	// Ініціалізуємо динамік один раз для всіх звуків
+	// Без звукового пристрою програма не запускається, щоб це не зʼясувалося лише під час тривоги
+	if err := initAudio(config); err != nil {
+		if !config.AllowNoAudio {
+			logFatal("Аудіопристрій недоступний: %v\nПеревірте звукову карту (aplay -l), права користувача (група audio) та audio_device. Щоб працювати без звуку, вкажіть \"allow_no_audio\": true", err)
+		}
+		logWarn("УВАГА! Аудіопристрій недоступний: %v. Програма працює без звуку (allow_no_audio), події лише записуються у лог та надсилаються у сповіщення", err)
+	}
+
+	// Якщо вказано прапорець test-audio, відтворюємо звуки та виходимо
+	if *testAudio {
+		playTestAudio(config, flag.Arg(0))
+		return
+	}
+
+	// У режимі симуляції відповіді сервера читаються з файлу
+	if *simulate != "" {
+		simulator, err = loadSimulator(*simulate, *simulateInterval)
+		if err != nil {
+			logFatal("Помилка завантаження файлу симуляції: %v", err)
+		}
+		log.Printf("Режим симуляції: %d відповідей з %s", len(simulator.snapshots), *simulate)
+	}
 
 	// Завантажуємо попередній стан
 	state, err := loadState(*statePath)
 	if err != nil {
-		log.Printf("Не вдалося завантажити попередній стан: %v", err)
-		state = &State{
-			ActiveAlertTypes: make(map[string]bool),
-			LastUpdate:       "",
-			LastPlayed:       make(map[string]time.Time),
-		}
+		logWarn("Не вдалося завантажити попередній стан: %v", err)
+		state = &State{}
+		migrateState(state)
+	}
+
+	// Відновлюємо кешовану відповідь, якщо вона ще актуальна
+	cachedAlerts, cachedLastUpdate, hasCache := cachedResponse(state, config)
+	if hasCache {
+		log.Printf("Відновлено кешовану відповідь від %s, активних подій: %d", state.Cache.FetchedAt.Format(time.RFC3339), len(cachedAlerts))
+	}
+
+	client := newHTTPClient(config)
+	source, err := newAlertSource(config, client)
+	if err != nil {
+		logFatal("Помилка створення джерела тривог: %v", err)
 	}
 
 	// Синхронізація часу з сервером
-	_, lastUpdate, err := fetchAlerts(config) // Прибираємо змінну alerts
+	alerts, lastUpdate, err := source.Fetch(context.Background())
+	startupResult := FetchResult{Alerts: alerts, LastUpdate: lastUpdate, Err: err}
+	var restored *FetchResult // Кешована відповідь, якщо сервер недоступний під час запуску
 	if err != nil {
-		log.Fatalf("Помилка отримання даних під час запуску: %v", err)
+		if !hasCache {
+			logFatal("Помилка отримання даних під час запуску: %v", err)
+		}
+		logError("Помилка отримання даних під час запуску: %v, використовуємо кешовану відповідь", err)
+		lastUpdate = cachedLastUpdate
+		startupResult = FetchResult{Alerts: cachedAlerts, LastUpdate: cachedLastUpdate}
+		restored = &startupResult
+	} else if updateResponseCache(state, config, alerts, lastUpdate) {
+		saveState(state, *statePath)
 	}
 	log.Printf("Синхронізація: час з сервера: %s, час у state.json: %s", lastUpdate, state.LastUpdate)
 
@@ -105,245 +510,1657 @@ func main() {
 
 	// Перевіряємо, що час у state.json синхронізовано
 	if state.LastUpdate != lastUpdate {
-		log.Fatalf("Помилка синхронізації часу: час у state.json (%s) не збігається з часом сервера (%s)", state.LastUpdate, lastUpdate)
+		logFatal("Помилка синхронізації часу: час у state.json (%s) не збігається з часом сервера (%s)", state.LastUpdate, lastUpdate)
+	}
+
+	// Ініціалізуємо час останнього відтворення для подій, що вже активні при запуску
+	for alertType := range state.ActiveAlertTypes {
+		if _, ok := state.LastPlayed[alertType]; !ok {
+			initStartupLastPlayed(state, config, alertType, state.LastUpdate, time.Now().UTC())
+			saveState(state, *statePath)
+		}
 	}
 
 	// Визначаємо локальну часову зону
 	location, err := time.LoadLocation(config.TimeZone)
 	if err != nil {
-		log.Fatalf("Помилка завантаження часової зони: %v", err)
+		logFatal("Помилка завантаження часової зони: %v", err)
+	}
+
+	// Один цикл перевірки для запуску з cron: код завершення показує, чи є активні тривоги
+	if *once {
+		settings := &atomic.Pointer[Settings]{}
+		settings.Store(&Settings{Config: config, Location: location, Client: client, Source: source})
+		code := runOnce(settings, state, startupResult, *statePath)
+		if config.PIDFile != "" {
+			releasePIDFile(config.PIDFile)
+		}
+		if logFile != nil {
+			logFile.Close()
+		}
+		os.Exit(code)
 	}
 
+	// Завершуємо роботу за сигналом SIGINT (Ctrl+C) або SIGTERM (systemd)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Початкову синхронізацію завершено, програма готова до роботи
+	notifySystemd(daemon.SdNotifyReady)
+
 	// Основна логіка програми
-	runMainLoop(config, state, location, *statePath)
+	settings := &atomic.Pointer[Settings]{}
+	settings.Store(&Settings{Config: config, Location: location, Client: client, Source: source})
+	runMainLoop(ctx, settings, state, *configPath, *statePath, restored)
+	notifySystemd(daemon.SdNotifyStopping)
+
+	// Зберігаємо стан перед виходом
+	state.mu.Lock()
+	saveState(state, *statePath)
+	state.mu.Unlock()
+	log.Println("Роботу завершено")
 }
 
-func runMainLoop(config *Config, state *State, location *time.Location, statePath string) {
-	// Встановлюємо інтервал запитів до сервера
-	requestInterval := time.Duration(config.RequestIntervalSec) * time.Second
-	if config.RequestIntervalSec <= 0 {
-		requestInterval = 30 * time.Second // Значення за замовчуванням
-	}
+// FetchResult - результат одного запиту до сервера
+type FetchResult struct {
+	Alerts     []Alert
+	LastUpdate string
+	Err        error
+}
 
-	// Основний цикл
-	for {
-		// Крок 1: Запит на отримання даних з сервера
-		alerts, lastUpdate, err := fetchAlerts(config)
-		if err != nil {
-			log.Printf("Помилка отримання даних: %v", err)
-			time.Sleep(requestInterval)
-			continue
+// AlertEvent - подія для програвача, яку формує обробник стану
+type AlertEvent struct {
+	Kind      string // start, end, repeat, chime, escalate, deescalation або summary
+	AlertType string
+	Time      string
+	Immediate bool          // Термінова подія, звук відтворюється без сигналу уваги
+	Remaining int           // Кількість подій, що залишились активними після закінчення цієї
+	Duration  time.Duration // Тривалість події для end, 0 - невідомо
+	Active    []string      // Усі активні події після цієї зміни
+	Audio     string        // Файл замість звичайного звуку: повтор з урахуванням ескалації або звук переходу
+	Handoff   string        // Перехід між типами подій "FROM>TO", частиною якого є подія
+	Region    string        // Регіон, з якого надійшла подія, якщо відомо
+	AllClear  bool          // Для end: лунає звук відбою (не більше одного за опитування)
+	Snoozed   bool          // Для end: відбій пропущено, бо діяла тиша (snooze_skip_all_clear)
+	Text      string        // Текст добового підсумку для summary
+}
+
+// Якщо restored не nil, кешована відповідь обробляється першою, ще до відповіді сервера
+func runMainLoop(ctx context.Context, settings *atomic.Pointer[Settings], state *State, configPath, statePath string, restored *FetchResult) {
+	config := settings.Load().Config
+
+	results := make(chan FetchResult)
+	events := make(chan AlertEvent)
+	played := make(chan struct{})
+
+	// Сигнал SIGHUP перечитує конфігурацію без перезапуску
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	// Основний цикл сповіщає про появу та зникнення активних тривог, щоб змінити частоту запитів
+	activeChanged := make(chan bool, 1)
+	state.mu.RLock()
+	wasActive := len(state.ActiveAlertTypes) > 0
+	state.mu.RUnlock()
+	activeChanged <- wasActive
+
+	startStatusServer(ctx, settings, state, requestIntervalFor(config))
+	publisher := startMQTT(config, state)
+	defer publisher.close()
+	go func() {
+		if restored != nil {
+			select {
+			case results <- *restored:
+			case <-ctx.Done():
+				return
+			}
 		}
+		runFetcher(ctx, settings, activeChanged, results)
+	}()
+	go runPlayer(settings, events, played)
+
+	// Якщо systemd очікує сигнали watchdog, надсилаємо їх після кожного успішного запиту,
+	// тож завислий основний цикл буде перезапущено
+	watchdog := systemdWatchdogEnabled()
+
+	evaluator := &Evaluator{
+		config:    config,
+		state:     state,
+		location:  settings.Load().Location,
+		statePath: statePath,
+		firstPoll: true,
+	}
 
-		log.Printf("Час з сервера (UTC): %s", lastUpdate)
+	// Планований перезапуск для обмеження часу роботи
+	maxRuntime := time.Duration(config.MaxRuntimeSec) * time.Second
+	startedAt := time.Now()
+	deferLogged := false
+	if maxRuntime > 0 {
+		log.Printf("Планований перезапуск після %s (не раніше %s), якщо не буде активних тривог", maxRuntime, startedAt.Add(maxRuntime).Format("2006-01-02 15:04:05"))
+	}
+
+	// Основний цикл: обробляємо відповіді та передаємо події програвачу по черзі.
+	// Відповіді обробляються і під час відтворення, щоб важливіша подія могла перервати звук
+	var queue []AlertEvent
+	var current AlertEvent
+	playing := false
+	finished := false
+	for {
+		select {
+		case result, ok := <-results:
+			// Джерело завершило роботу (симуляція) - виходимо, щойно програвач відтворить усі звуки
+			if !ok {
+				results = nil
+				finished = true
+				break
+			}
+			if watchdog && result.Err == nil {
+				notifySystemd(daemon.SdNotifyWatchdog)
+			}
+			for _, event := range evaluator.process(result) {
+				publisher.publishEvent(event)
+				if playing && eventPriority(config, event) > eventPriority(config, current) {
+					log.Printf("Відтворення події %s (%s) перервано подією %s", current.Kind, current.AlertType, event.AlertType)
+					playback.interrupt()
+					queue = append([]AlertEvent{event}, queue...)
+					continue
+				}
+				var playingEvent *AlertEvent
+				if playing {
+					playingEvent = &current
+				}
+				queue = enqueueAudioEvent(config, queue, event, playingEvent)
+			}
 
-		// Крок 2: Порівняння часу останнього оновлення
-		if state.LastUpdate != lastUpdate {
-			log.Printf("Оновлюємо час у state.json: %s -> %s", state.LastUpdate, lastUpdate)
-			state.LastUpdate = lastUpdate
-			saveState(state, statePath)
+			state.mu.RLock()
+			isActive := len(state.ActiveAlertTypes) > 0
+			state.mu.RUnlock()
+			if isActive != wasActive {
+				wasActive = isActive
+				// Попереднє непрочитане значення застаріло, залишаємо лише актуальне
+				select {
+				case <-activeChanged:
+				default:
+				}
+				activeChanged <- isActive
+			}
+		case <-played:
+			playing = false
+		case <-reload:
+			updated, err := reloadSettings(configPath)
+			if err != nil {
+				logError("Помилка перечитування конфігурації, продовжуємо зі старими налаштуваннями: %v", err)
+				break
+			}
+			settings.Store(updated)
+			debugTimeParsing.Store(updated.Config.Debug)
+			config = updated.Config
+			evaluator.config = updated.Config
+			evaluator.location = updated.Location
+			log.Printf("Конфігурацію перечитано з %s", configPath)
+		case <-ctx.Done():
+			return
 		}
 
-		// Крок 3: Перевірка, чи активна подія
-		currentAlerts := make(map[string]bool)
-		for _, alert := range alerts {
-			currentAlerts[alert.Type] = true
+		if !playing && len(queue) > 0 {
+			current, queue = queue[0], queue[1:]
+			events <- current
+			playing = true
+		}
+		if finished && !playing && len(queue) == 0 {
+			log.Println("Симуляцію завершено")
+			return
 		}
 
-		checkAndHandleStateChange(state, currentAlerts, alerts, lastUpdate, config, statePath)
+		if maxRuntime > 0 {
+			state.mu.RLock()
+			active := len(state.ActiveAlertTypes)
+			state.mu.RUnlock()
+			restart, deferred := maxRuntimeRestart(maxRuntime, time.Since(startedAt), active, playing || len(queue) > 0)
+			if restart {
+				log.Printf("Час роботи перевищив %s, завершуємо роботу для перезапуску", maxRuntime)
+				return
+			}
+			if deferred && !deferLogged {
+				log.Printf("Час роботи перевищив %s, але перезапуск відкладено: активних тривог %d", maxRuntime, active)
+				deferLogged = true
+			}
+		}
+	}
+}
 
-		// Крок 4: Перевірка необхідності відтворення звуку
-		checkAndPlayRepeatAudio(state, config, location, statePath)
+// Чи завершувати роботу для перезапуску після max_runtime_sec. Звук, що лунає, дограє до кінця,
+// а поки є активні тривоги, перезапуск відкладається (deferred)
+func maxRuntimeRestart(maxRuntime, elapsed time.Duration, active int, playing bool) (restart, deferred bool) {
+	if maxRuntime <= 0 || elapsed < maxRuntime || playing {
+		return false, false
+	}
+	return active == 0, active > 0
+}
 
-		time.Sleep(requestInterval)
+// Розраховує паузу перед наступним запитом: після кожної помилки поспіль пауза подвоюється,
+// але не перевищує maxDelay. До паузи додається випадкова частка до 10% (jitter від 0 до 1)
+func backoffDelay(base, maxDelay time.Duration, failures int, jitter float64) time.Duration {
+	if failures <= 0 {
+		return base
+	}
+	delay := base
+	for i := 0; i < failures && delay < maxDelay; i++ {
+		delay *= 2
 	}
+	delay = min(delay, maxDelay)
+	return delay + time.Duration(jitter*0.1*float64(delay))
 }
 
-func loadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path) // Заміщено ioutil.ReadFile на os.ReadFile
-	if err != nil {
-		return nil, err
+// Додає до паузи випадкове зміщення в межах ±request_jitter_sec, щоб екземпляри програми
+// не надсилали запити одночасно. r - випадкове число з [0, 1). Пауза не менша за 1 секунду
+func applyPollJitter(delay time.Duration, config *Config, r float64) time.Duration {
+	if config.RequestJitterSec <= 0 {
+		return delay
 	}
+	jitter := time.Duration(config.RequestJitterSec) * time.Second
+	return max(delay+time.Duration((2*r-1)*float64(jitter)), time.Second)
+}
 
-	// Видаляємо коментарі з JSON
-	cleanedData := removeComments(data)
+// Отримує дані з сервера з заданим інтервалом і передає їх обробнику
+func runFetcher(ctx context.Context, settings *atomic.Pointer[Settings], activeChanged <-chan bool, results chan<- FetchResult) {
+	health := &SourceHealth{URL: sourceName(settings.Load().Config)}
+	usingFallback := false
+	currentBackoff := requestIntervalFor(settings.Load().Config)
+	active := false
 
-	var config Config
-	err = json.Unmarshal(cleanedData, &config)
-	return &config, err
+	for {
+		// Налаштування читаються на кожному кроці, щоб зміни після SIGHUP діяли одразу
+		current := settings.Load()
+		config := current.Config
+		requestInterval := pollInterval(config, active)
+		maxBackoff := maxBackoffFor(config)
+		health.URL = sourceName(config)
+
+		started := time.Now()
+		alerts, lastUpdate, err := current.Source.Fetch(ctx)
+		// Запит перервано через завершення роботи - це не помилка джерела
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errSimulationDone) {
+			log.Println("Відповіді симуляції закінчились")
+			close(results)
+			return
+		}
+		health.record(time.Since(started), err)
+		if config.Debug {
+			logDebug("Джерело %s: затримка %s, помилок поспіль: %d", health.URL, health.Latency, health.ConsecutiveFailures)
+		}
+
+		// Якщо мережа недоступна довгий час, читаємо тривоги з резервного файлу
+		if err != nil && shouldUseFallback(config, health) {
+			if !usingFallback {
+				logWarn("Мережеве джерело недоступне %d запитів поспіль, переходимо на резервний файл %s", health.ConsecutiveFailures, config.FallbackAlertFile)
+				usingFallback = true
+			}
+			alerts, lastUpdate, err = readFallbackAlerts(config.FallbackAlertFile)
+		} else if err == nil && usingFallback {
+			log.Println("Мережеве джерело знову доступне, резервний файл більше не використовується")
+			usingFallback = false
+		}
+
+		select {
+		case results <- FetchResult{Alerts: alerts, LastUpdate: lastUpdate, Err: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		// При помилках поспіль збільшуємо паузу між запитами
+		delay := backoffDelay(requestInterval, maxBackoff, health.ConsecutiveFailures, mathrand.Float64())
+		delay = applyPollJitter(delay, config, mathrand.Float64())
+		if simulator != nil {
+			delay = simulator.interval
+		}
+		if backoff := backoffDelay(requestInterval, maxBackoff, health.ConsecutiveFailures, 0); backoff != currentBackoff {
+			log.Printf("Пауза між запитами змінена: %s -> %s", currentBackoff, backoff)
+			currentBackoff = backoff
+		}
+
+		// Очікуємо наступний запит, але не довше ніж до завершення роботи.
+		// Якщо тривога почалась або закінчилась, пауза перераховується одразу
+		waitStarted := time.Now()
+		timer := time.NewTimer(delay)
+	wait:
+		for {
+			select {
+			case <-timer.C:
+				break wait
+			case active = <-activeChanged:
+				requestInterval = pollInterval(config, active)
+				delay = backoffDelay(requestInterval, maxBackoff, health.ConsecutiveFailures, mathrand.Float64())
+				delay = applyPollJitter(delay, config, mathrand.Float64())
+				timer.Reset(max(delay-time.Since(waitStarted), 0))
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}
 }
 
-func removeComments(data []byte) []byte {
-	var buffer bytes.Buffer
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-	commentRegex := regexp.MustCompile(`^\s*(//|#)`)
+// Відтворює звуки для подій і повідомляє про завершення кожної
+func runPlayer(settings *atomic.Pointer[Settings], events <-chan AlertEvent, played chan<- struct{}) {
+	for event := range events {
+		current := settings.Load()
+		config, location := current.Config, current.Location
+		playback.reset()
+
+		// Добовий підсумок лише надсилається у сповіщення, звуку немає
+		if event.Kind == "summary" {
+			notifySummary(config, current.Client, event)
+			played <- struct{}{}
+			continue
+		}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !commentRegex.MatchString(line) {
-			buffer.WriteString(line + "\n")
+		// Сповіщення надсилаються незалежно від відтворення звуку
+		if event.Kind == "start" || event.Kind == "end" {
+			syslogTransition(event.Kind, event.AlertType, event.Time)
+			runHook(config, event)
+			notifyTelegram(config, event)
+			notifyEmail(config, event)
+			notifyDesktop(config, event)
+			sendWebhook(config, current.Client, event)
 		}
+		if event.Kind == "escalate" {
+			sendEscalationWebhook(config, current.Client, event)
+		}
+
+		// Для типів поза білим списком або у чорному списку звук не відтворюється
+		if event.AlertType != "" && !audioAllowed(config, event.AlertType) {
+			if config.Debug {
+				logDebug("Звук події %s (%s) вимкнено налаштуваннями audio_types", event.Kind, event.AlertType)
+			}
+			played <- struct{}{}
+			continue
+		}
+
+		// У тихі години стан і лог ведуться як завжди, приглушується лише звук
+		if quietSuppresses(config, location, event.Kind, time.Now()) {
+			log.Printf("Звук події %s (%s) приглушено: тихі години", event.Kind, event.AlertType)
+			played <- struct{}{}
+			continue
+		}
+
+		switch event.Kind {
+		case "start":
+			if !event.Immediate {
+				playAttentionTone(config)
+			}
+			// Голосове оголошення лунає після звуку події, а якщо синтез не вдався - лише звук
+			announcement := prepareAnnouncement(config, location, event)
+			if announcement == "" || !announcementConfig(config).ReplaceSiren {
+				if event.Audio != "" {
+					playAudio(config, event.Audio) // Звук переходу з іншої події
+				} else {
+					playPlaylist(config, config.AudioFiles[event.AlertType])
+				}
+			}
+			if announcement != "" {
+				playAudio(config, announcement)
+				os.Remove(announcement)
+			}
+		case "end":
+			// Поки інші події активні, замість відбою лунає сигнал покращення ситуації
+			if config.EnableDeescalation && event.Remaining > 0 {
+				break
+			}
+			// Відбій, що є частиною переходу до іншої події, не звучить
+			if event.Handoff != "" {
+				break
+			}
+			if event.Snoozed {
+				log.Printf("Подія %s закінчилась під час тиші, відбій не лунає", event.AlertType)
+				break
+			}
+			if !event.AllClear {
+				log.Printf("Подія %s закінчилась, відбій не лунає: активні події з all_clear_types ще тривають, подія не входить до списку або відбій лунає для іншої події", event.AlertType)
+				break
+			}
+			if config.AttentionOnClear {
+				playAttentionTone(config)
+			}
+			playAudio(config, config.AlertOnEmpty)
+		case "deescalation":
+			playAudio(config, config.DeescalationAudio)
+		case "repeat":
+			if config.AttentionOnRepeat {
+				playAttentionTone(config)
+			}
+			playAudio(config, event.Audio)
+		case "chime":
+			playAudio(config, config.StillActiveChime)
+		case "escalate":
+			if config.EscalateAudioFile != "" {
+				playAudio(config, config.EscalateAudioFile)
+			} else {
+				playPlaylist(config, config.AudioFiles[event.AlertType])
+			}
+		}
+		audioPlays.WithLabelValues(event.AlertType, event.Kind).Inc()
+		played <- struct{}{}
 	}
+}
+
+// Evaluator обробляє відповіді сервера, оновлює стан і формує події
+type Evaluator struct {
+	config    *Config
+	state     *State
+	location  *time.Location
+	statePath string
+	firstPoll bool
+	expired   map[string]bool // Події, що вже залоговані як застарілі
+	// Час останньої ескалації непідтверджених подій
+	escalated map[string]time.Time
 
-	return buffer.Bytes()
+	lastUpdateChanged time.Time // Коли час оновлення від сервера змінився востаннє
+	staleReported     bool      // Попередження про застарілі дані вже записано
 }
 
-func fetchAlerts(config *Config) ([]Alert, string, error) {
-	req, err := http.NewRequest("GET", config.APIURL, nil)
-	if err != nil {
-		return nil, "", err
-	}
+func (e *Evaluator) process(result FetchResult) []AlertEvent {
+	config, state, statePath := e.config, e.state, e.statePath
 
-	// Встановлюємо заголовок авторизації
-	req.Header.Set("Authorization", config.AuthHeader)
+	// Стан змінюється лише тут, інші горутини читають його через Snapshot
+	state.mu.Lock()
+	defer state.mu.Unlock()
 
-	if config.Debug {
-		log.Printf("Відправка запиту: %s", config.APIURL)
-		// log.Printf("Заголовок Authorization: %s", config.AuthHeader) // Прибрано з логів
+	// Добовий підсумок не залежить від результату запиту і надсилається разом з іншими подіями
+	var events []AlertEvent
+	summary, summaryChanged := checkDailySummary(state, config, e.location, time.Now().UTC())
+	if summary != "" {
+		events = append(events, AlertEvent{Kind: "summary", Time: time.Now().UTC().Format(time.RFC3339), Text: summary})
+	}
+	if summaryChanged {
+		saveState(state, statePath)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, "", err
+	// Крок 1: Перевірка результату запиту
+	if result.Err != nil {
+		logEvent(slog.LevelError, fmt.Sprintf("Помилка отримання даних: %v", result.Err),
+			"event", "fetch_error", "error", result.Err.Error())
+		return events
 	}
-	defer resp.Body.Close()
+	state.LastFetchOK = time.Now()
+	lastUpdate := result.LastUpdate
 
-	if config.Debug {
-		log.Printf("Отримано відповідь: %d", resp.StatusCode)
+	log.Printf("Час з сервера (UTC): %s", lastUpdate)
+
+	alerts := applyTypeGroups(result.Alerts, config)
+	e.markExpiredAlerts(alerts, time.Now())
+	alerts = dedupeAlertTypes(alerts)
+
+	// Усі зміни стану за одне опитування зберігаються одним записом
+	changes := 0
+	if updateResponseCache(state, config, alerts, lastUpdate) {
+		changes++
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("неочікуваний статус відповіді: %d", resp.StatusCode)
+	// Крок 2: Порівняння часу останнього оновлення
+	e.checkLastUpdate(state.LastUpdate, lastUpdate, time.Now())
+	if state.LastUpdate != lastUpdate {
+		log.Printf("Оновлюємо час у state.json: %s -> %s", state.LastUpdate, lastUpdate)
+		state.LastUpdate = lastUpdate
+		changes++
 	}
 
-	var regions []Region
-	err = json.NewDecoder(resp.Body).Decode(&regions)
-	if err != nil {
-		return nil, "", err
+	// Крок 3: Перевірка, чи активна подія
+	currentAlerts := make(map[string]bool)
+	for _, alert := range alerts {
+		currentAlerts[alert.Type] = true
 	}
+	updateSeenSince(state, currentAlerts, time.Now())
 
-	if len(regions) > 0 {
-		region := regions[0]
-		if len(region.ActiveAlerts) > 0 {
-			return region.ActiveAlerts, region.ActiveAlerts[0].LastUpdate, nil
+	// Після перезапуску не відтворюємо звуки, якщо ситуація вже була оброблена
+	hash := responseHash(alerts, lastUpdate)
+	if e.firstPoll && isAlreadyProcessed(state, config, hash) {
+		log.Printf("Відповідь сервера не змінилась з %s, звуки не відтворюються", state.ProcessedAt.Format(time.RFC3339))
+	} else {
+		changed := checkAndHandleStateChange(state, currentAlerts, alerts, lastUpdate, config)
+		skipSnoozedAllClear(changed, config, time.Now())
+		changes += len(changed)
+		appendHistory(config, e.location, changed, time.Now())
+		active := activeAlertTypes(state)
+		for i := range changed {
+			changed[i].Active = active
 		}
-		return nil, region.LastUpdate, nil
-	}
-	return nil, "", nil
-}
+		events = append(events, changed...)
+		activeAlerts.Set(float64(len(active)))
 
-func playAudio(path string) {
-	if path == "" {
-		log.Println("Аудіофайл не вказано")
-		return
-	}
+		// Програма запущена під час тривоги: відлік повторів за startup_last_played
+		if e.firstPoll {
+			for _, event := range changed {
+				if event.Kind == "start" {
+					initStartupLastPlayed(state, config, event.AlertType, event.Time, time.Now().UTC())
+				}
+			}
+		}
 
-	f, err := os.Open(path)
-	if err != nil {
-		log.Printf("Помилка відкриття аудіофайлу: %v", err)
-		return
+		// Крок 4: Перевірка необхідності відтворення звуку
+		repeats := checkAndPlayRepeatAudio(state, config, e.location, statePath)
+		changes += len(repeats)
+		events = append(events, repeats...)
+
+		chimes := checkStillActiveChime(state, config, time.Now().UTC())
+		changes += len(chimes)
+		events = append(events, chimes...)
+
+		events = append(events, e.checkUnacknowledged(state, config, time.Now().UTC())...)
 	}
-	defer f.Close()
+	e.firstPoll = false
+	logAlertTimers(state, config, time.Now().UTC())
 
-	streamer, format, err := mp3.Decode(f)
-	if err != nil {
-		log.Printf("Помилка декодування аудіофайлу: %v", err)
-		return
+	// Запамʼятовуємо оброблену відповідь
+	if rememberProcessed(state, config, hash) {
+		changes++
 	}
-	defer streamer.Close()
 
-	speaker.Init(format.SampleRate, format.SampleRate.N(time.Second/10))
-	speaker.Play(streamer)
-	select {
-	case <-time.After(format.SampleRate.D(streamer.Len())):
+	if changes > 0 {
+		saveState(state, statePath)
+		if config.Debug && changes > 1 {
+			logDebug("Стан збережено одним записом замість %d", changes)
+		}
 	}
+
+	return events
 }
 
-func loadState(path string) (*State, error) {
+func loadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path) // Заміщено ioutil.ReadFile на os.ReadFile
 	if err != nil {
-		if os.IsNotExist(err) {
-			return &State{ActiveAlertTypes: make(map[string]bool)}, nil
-		}
 		return nil, err
 	}
-	var state State
-	err = json.Unmarshal(data, &state)
-	if state.LastPlayed == nil {
-		state.LastPlayed = make(map[string]time.Time) // Ініціалізуємо порожню карту
-	}
-	return &state, err
-}
 
-func saveState(state *State, path string) {
-	// Перетворюємо порожню карту LastPlayed у null для коректного збереження
-	if len(state.LastPlayed) == 0 {
-		state.LastPlayed = nil
+	// Видаляємо коментарі з JSON
+	cleanedData, err := removeComments(data)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := json.Marshal(state)
+	// Підставляємо значення змінних середовища замість ${ЗМІННА}
+	cleanedData, err = expandEnvPlaceholders(cleanedData)
 	if err != nil {
-		log.Printf("Помилка збереження стану: %v", err)
-		return
+		return nil, err
 	}
-	err = os.WriteFile(path, data, 0644) // Заміщено ioutil.WriteFile на os.WriteFile
+
+	var config Config
+	err = json.Unmarshal(cleanedData, &config)
 	if err != nil {
-		log.Printf("Помилка запису стану у файл: %v", err)
+		return &config, err
 	}
 
-	// Відновлюємо порожню карту після збереження
-	if state.LastPlayed == nil {
-		state.LastPlayed = make(map[string]time.Time)
+	// Токен можна не зберігати у файлі, а передати через змінну середовища
+	if config.AuthHeader == "" {
+		config.AuthHeader = os.Getenv("SIGNAL_APP_AUTH_HEADER")
 	}
+	return &config, nil
 }
 
-func setupLogging(config *Config) {
-	if config.LogToFile {
-		logFile, err := os.OpenFile(config.LogFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Fatalf("Помилка відкриття файлу логу: %v", err)
+var envPlaceholderRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Замінює ${ЗМІННА} значенням змінної середовища. Значення екрануються для JSON рядка.
+// Якщо змінну не задано, повертається помилка з переліком усіх відсутніх змінних
+func expandEnvPlaceholders(data []byte) ([]byte, error) {
+	var missing []string
+	expanded := envPlaceholderRegex.ReplaceAllFunc(data, func(match []byte) []byte {
+		name := string(envPlaceholderRegex.FindSubmatch(match)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+			return match
 		}
-		multiWriter := io.MultiWriter(os.Stdout, logFile)
-		log.SetOutput(multiWriter)
-	} else {
-		log.SetOutput(os.Stdout)
+		quoted, _ := json.Marshal(value)
+		return quoted[1 : len(quoted)-1]
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("не задано змінні середовища: %s", strings.Join(missing, ", "))
 	}
+	return expanded, nil
+}
+
+// Перевіряє обовʼязкові поля та допустимі значення. Повертає одну помилку з переліком усіх проблем
+func validateConfig(config *Config) error {
+	var errs []error
+	switch config.SourceType {
+	case "", sourceHTTP:
+		if config.APIURL == "" && len(config.APIURLs) == 0 {
+			errs = append(errs, errors.New("- не вказано api_url"))
+		}
+	case sourceFile:
+		if config.SourceFile == "" {
+			errs = append(errs, errors.New("- source_type \"file\" потребує source_file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("- невідомий source_type %q, можливі значення: http, file", config.SourceType))
+	}
+	if config.RequestJitterSec < 0 {
+		errs = append(errs, fmt.Errorf("- request_jitter_sec не може бути відʼємним, вказано %d", config.RequestJitterSec))
+	}
+	if config.RegionNameFilter != "" {
+		re, err := regexp.Compile(config.RegionNameFilter)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("- некоректний регулярний вираз region_name_filter %q: %v", config.RegionNameFilter, err))
+		}
+		config.regionNamePattern = re
+	}
+	if _, err := parseLogLevel(config.StdoutLogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("- stdout_log_level: %v", err))
+	}
+	if _, err := parseLogLevel(config.FileLogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("- file_log_level: %v", err))
+	}
+	if config.RequestIntervalSec < 0 {
+		errs = append(errs, fmt.Errorf("- request_interval_sec має бути не менше 1 (або 0 для значення за замовчуванням), вказано %d", config.RequestIntervalSec))
+	}
+	if config.RepeatIntervalMin < 0 {
+		errs = append(errs, fmt.Errorf("- repeat_interval_min не може бути відʼємним, вказано %d", config.RepeatIntervalMin))
+	}
+	if config.TimeZone == "" {
+		errs = append(errs, errors.New("- не вказано time_zone"))
+	} else if _, err := time.LoadLocation(config.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("- невідома часова зона time_zone %q: %v", config.TimeZone, err))
+	}
+	if method := requestMethod(config); method != http.MethodGet && !methodsWithBody[method] {
+		errs = append(errs, fmt.Errorf("- непідтримуваний http_method %q, можливі значення: GET, POST, PUT, PATCH", config.HTTPMethod))
+	} else if config.RequestBody != "" && !methodsWithBody[method] {
+		errs = append(errs, fmt.Errorf("- request_body не можна надсилати з методом %s, використайте POST, PUT або PATCH", method))
+	}
+	if config.ProxyURL != "" {
+		if proxy, err := url.Parse(config.ProxyURL); err != nil || proxy.Host == "" {
+			errs = append(errs, fmt.Errorf("- некоректний proxy_url %q, очікується адреса на кшталт http://host:port або socks5://host:port", config.ProxyURL))
+		}
+	}
+	for alertType, steps := range config.Escalation {
+		for _, step := range steps {
+			if step.AfterMin < 0 || step.AudioFile == "" {
+				errs = append(errs, fmt.Errorf("- escalation для %s: потрібні невідʼємний after_min та audio_file, вказано %d, %q", alertType, step.AfterMin, step.AudioFile))
+			}
+		}
+	}
+	if config.EnableRepeatAudio && config.RepeatAudioFile == "" && len(config.RepeatAudioFiles) == 0 {
+		errs = append(errs, errors.New("- enable_repeat_audio увімкнено, але не вказано repeat_audio_file"))
+	}
+	return errors.Join(errs...)
+}
+
+// Видаляє коментарі // та # до кінця рядка і блоки /* */. Символи всередині рядків JSON
+// (наприклад, http:// у адресах) не вважаються коментарями. Незакритий блок /* - помилка
+func removeComments(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	inString, escaped, inBlock := false, false, false
+	line, blockLine := 1, 0
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c == '\n' {
+			line++
+		}
+		switch {
+		case inBlock:
+			if c == '*' && i+1 < len(data) && data[i+1] == '/' {
+				inBlock = false
+				i++
+			} else if c == '\n' {
+				buffer.WriteByte(c) // Зберігаємо нумерацію рядків для повідомлень про помилки
+			}
+		case inString:
+			buffer.WriteByte(c)
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			buffer.WriteByte(c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			inBlock = true
+			blockLine = line
+			i++
+		case c == '#' || (c == '/' && i+1 < len(data) && data[i+1] == '/'):
+			// Пропускаємо до кінця рядка
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				line++
+				buffer.WriteByte('\n')
+			}
+		default:
+			buffer.WriteByte(c)
+		}
+	}
+
+	if inBlock {
+		return nil, fmt.Errorf("незакритий коментар /* у рядку %d", blockLine)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Створює HTTP клієнт, спільний для всіх запитів, щоб повторно використовувати зʼєднання
+func newHTTPClient(config *Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Без proxy_url використовуються змінні середовища HTTP_PROXY / HTTPS_PROXY
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			logError("Помилка у proxy_url, запити надсилаються без проксі: %v", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+	}
+	if config.InsecureSkipVerify {
+		logWarn("УВАГА: insecure_skip_verify увімкнено, сертифікат сервера НЕ перевіряється. Використовуйте лише для тестових серверів")
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Timeout: requestTimeout(config), Transport: transport}
+}
+
+// Метод запиту до сервера (http_method), за замовчуванням GET
+func requestMethod(config *Config) string {
+	if config.HTTPMethod == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(config.HTTPMethod)
+}
+
+// Методи, з якими можна надсилати request_body
+var methodsWithBody = map[string]bool{http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true}
+
+// Тип тіла запиту: JSON, якщо тіло є коректним JSON, інакше звичайний текст
+func requestContentType(body string) string {
+	if json.Valid([]byte(body)) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Повертає граничний час одного запиту до сервера
+func requestTimeout(config *Config) time.Duration {
+	if config.HTTPTimeoutSec <= 0 {
+		return 15 * time.Second // Значення за замовчуванням
+	}
+	return time.Duration(config.HTTPTimeoutSec) * time.Second
+}
+
+// Опитує джерела по черзі, починаючи з останнього справного, до першої успішної відповіді
+func fetchAlerts(ctx context.Context, client *http.Client, config *Config) ([]Alert, string, error) {
+	endpoints := apiEndpoints(config)
+	start := int(activeEndpoint.Load()) % len(endpoints)
+
+	var errs []error
+	for i := range endpoints {
+		index := (start + i) % len(endpoints)
+		endpoint := endpoints[index]
+		started := time.Now()
+		alerts, lastUpdate, err := fetchEndpoint(ctx, client, config, endpoint)
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		recordFetchMetrics(err)
+		endpointHealth.record(endpoint.URL, time.Since(started), err, len(endpoints) > 1)
+		if err != nil {
+			if len(endpoints) > 1 {
+				logWarn("Джерело %s недоступне: %v", endpoint.URL, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint.URL, err))
+			continue
+		}
+		if index != start {
+			log.Printf("Дані отримано з резервного джерела %s", endpoint.URL)
+		} else if config.Debug && len(endpoints) > 1 {
+			logDebug("Дані отримано з джерела %s", endpoint.URL)
+		}
+		activeEndpoint.Store(int64(index))
+		return alerts, lastUpdate, nil
+	}
+	if len(errs) == 1 {
+		return nil, "", errors.Unwrap(errs[0])
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+// Виконує запит до одного джерела
+func fetchEndpoint(ctx context.Context, client *http.Client, config *Config, endpoint APIEndpoint) ([]Alert, string, error) {
+	// Обмежуємо час запиту разом з читанням відповіді, запит також переривається при завершенні роботи
+	ctx, cancel := context.WithTimeout(ctx, endpointTimeout(config, endpoint))
+	defer cancel()
+
+	var body io.Reader
+	if config.RequestBody != "" {
+		body = strings.NewReader(config.RequestBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, requestMethod(config), endpoint.URL, body)
+	if err != nil {
+		return nil, "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", requestContentType(config.RequestBody))
+	}
+
+	// Встановлюємо заголовок авторизації
+	req.Header.Set("Authorization", endpoint.AuthHeader)
+
+	// Явно вказуємо бажаний формат відповіді
+	accept := config.AcceptHeader
+	if accept == "" {
+		accept = "application/json" // Значення за замовчуванням
+	}
+	req.Header.Set("Accept", accept)
+
+	// Умовний запит: сервер поверне 304, якщо дані не змінились
+	conditional.apply(req, endpoint.URL)
+
+	// Підписуємо запит, якщо цього вимагає провайдер
+	if err := signRequest(req, config, time.Now()); err != nil {
+		return nil, "", err
+	}
+
+	if config.Debug {
+		logDebug("Відправка запиту: %s %s", req.Method, endpoint.URL)
+		if config.HMACSecret != "" {
+			logDebug("Запит підписано HMAC, ключ: ****")
+		}
+		// log.Printf("Заголовок Authorization: %s", config.AuthHeader) // Прибрано з логів
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	requestLatency.record(config, endpoint.URL, time.Since(started))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if config.Debug {
+		logDebug("Отримано відповідь: %d", resp.StatusCode)
+	}
+
+	// Дані не змінились - використовуємо попередньо розібрану відповідь
+	if resp.StatusCode == http.StatusNotModified {
+		if alerts, lastUpdate, ok := conditional.cached(endpoint.URL); ok {
+			if config.Debug {
+				logDebug("Дані не змінились з попереднього запиту")
+			}
+			return alerts, lastUpdate, nil
+		}
+		return nil, "", fmt.Errorf("сервер повернув 304 без попередньої відповіді")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("неочікуваний статус відповіді: %d", resp.StatusCode)
+	}
+
+	alerts, lastUpdate, err := decodeResponse(resp, config)
+	if err != nil {
+		return nil, "", err
+	}
+	conditional.store(endpoint.URL, resp.Header, alerts, lastUpdate)
+	return alerts, lastUpdate, nil
+}
+
+// Розбирає відповідь сервера у списку подій
+func decodeResponse(resp *http.Response, config *Config) ([]Alert, string, error) {
+	// Формат відповіді визначається налаштуванням або заголовком Content-Type
+	apiFormat := config.APIFormat
+	if apiFormat == "" && isXMLContentType(resp.Header.Get("Content-Type")) {
+		apiFormat = "cap"
+	}
+	switch apiFormat {
+	case "cap":
+		return decodeCAP(resp.Body, config)
+	case "boolean":
+		return decodeBoolean(resp.Body, config)
+	}
+
+	var regions []Region
+	err := json.NewDecoder(resp.Body).Decode(&regions)
+	if err != nil {
+		return nil, "", err
+	}
+	return alertsFromRegions(regions, config)
+}
+
+// Повертає події та час оновлення налаштованих регіонів відповіді
+func alertsFromRegions(regions []Region, config *Config) ([]Alert, string, error) {
+	// Вибираємо регіони зі списку та за назвою, якщо їх задано
+	if len(config.Regions) > 0 || config.regionNamePattern != nil {
+		selected := regions
+		if len(config.Regions) > 0 {
+			selected = selectRegions(regions, config.Regions)
+		}
+		if config.regionNamePattern != nil {
+			selected = filterRegionsByName(selected, config.regionNamePattern)
+		}
+		if len(selected) == 0 {
+			return nil, "", fmt.Errorf("у відповіді немає жодного з налаштованих регіонів")
+		}
+		alerts, lastUpdate := aggregateRegions(selected)
+		return alerts, lastUpdate, nil
+	}
+
+	if len(regions) > 0 {
+		region := regions[0]
+		if len(region.ActiveAlerts) > 0 {
+			return region.ActiveAlerts, region.ActiveAlerts[0].LastUpdate, nil
+		}
+		return nil, region.LastUpdate, nil
+	}
+	return nil, "", nil
+}
+
+// Позначає у state.Expired типи подій, усі події яких оновлені раніше за max_alert_age_min.
+// Такі події лишаються активними: початок і відбій визначає лише сервер, пропускаються тільки повтори
+func (e *Evaluator) markExpiredAlerts(alerts []Alert, now time.Time) {
+	e.state.Expired = make(map[string]bool)
+	if e.config.MaxAlertAgeMin <= 0 {
+		return
+	}
+	if e.expired == nil {
+		e.expired = make(map[string]bool)
+	}
+
+	maxAge := time.Duration(e.config.MaxAlertAgeMin) * time.Minute
+	fresh := make(map[string]bool)
+	logged := make(map[string]bool)
+	for _, alert := range alerts {
+		updated, err := parseAPITime(alert.LastUpdate)
+		if err != nil || now.Sub(updated) <= maxAge {
+			fresh[alert.Type] = true
+			continue
+		}
+		e.state.Expired[alert.Type] = true
+		key := alert.Type + "@" + alert.LastUpdate
+		logged[key] = true
+		if !e.expired[key] {
+			log.Printf("Подія %s застаріла (оновлена %s), повторні сигнали для неї не лунають", alert.Type, alert.LastUpdate)
+		}
+	}
+	for alertType := range fresh {
+		delete(e.state.Expired, alertType)
+	}
+	e.expired = logged
+}
+
+// RegionRef - регіон у налаштуваннях: індекс у відповіді (число) або назва чи ідентифікатор (рядок)
+type RegionRef struct {
+	Index int
+	Name  string
+}
+
+func (r *RegionRef) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Index); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &r.Name)
+}
+
+// Вибирає з відповіді регіони, вказані у налаштуваннях
+func selectRegions(regions []Region, refs []RegionRef) []Region {
+	var selected []Region
+	for _, ref := range refs {
+		if ref.Name == "" {
+			if ref.Index >= 0 && ref.Index < len(regions) {
+				selected = append(selected, regions[ref.Index])
+			}
+			continue
+		}
+		for _, region := range regions {
+			if ref.Name == region.RegionID || ref.Name == region.RegionName || ref.Name == region.RegionEngName {
+				selected = append(selected, region)
+			}
+		}
+	}
+	return selected
+}
+
+// Залишає регіони, назва яких відповідає регулярному виразу region_name_filter
+func filterRegionsByName(regions []Region, pattern *regexp.Regexp) []Region {
+	var selected []Region
+	for _, region := range regions {
+		if pattern.MatchString(region.RegionName) || pattern.MatchString(region.RegionEngName) {
+			selected = append(selected, region)
+		}
+	}
+	return selected
+}
+
+// Обʼєднує події кількох регіонів. Час оновлення - найновіший серед них
+func aggregateRegions(regions []Region) ([]Alert, string) {
+	var alerts []Alert
+	lastUpdate := ""
+	for _, region := range regions {
+		alerts = append(alerts, region.ActiveAlerts...)
+		if region.LastUpdate > lastUpdate {
+			lastUpdate = region.LastUpdate
+		}
+		for _, alert := range region.ActiveAlerts {
+			if alert.LastUpdate > lastUpdate {
+				lastUpdate = alert.LastUpdate
+			}
+		}
+	}
+	return alerts, lastUpdate
+}
+
+// Замінює типи подій, що входять до груп type_groups, на назву групи.
+// Тип з кількох груп належить до першої з них за абеткою
+func applyTypeGroups(alerts []Alert, config *Config) []Alert {
+	if len(config.TypeGroups) == 0 {
+		return alerts
+	}
+
+	groupOf := make(map[string]string)
+	for _, group := range slices.Sorted(maps.Keys(config.TypeGroups)) {
+		for _, member := range config.TypeGroups[group] {
+			if _, ok := groupOf[member]; !ok {
+				groupOf[member] = group
+			}
+		}
+	}
+
+	grouped := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if group, ok := groupOf[alert.Type]; ok {
+			if config.Debug && group != alert.Type {
+				logDebug("Подія %s належить до групи %s", alert.Type, group)
+			}
+			alert.Type = group
+		}
+		grouped = append(grouped, alert)
+	}
+	return grouped
+}
+
+// Залишає одну подію кожного типу. Якщо тип активний у кількох регіонах, зберігається
+// найраніша за lastUpdate, тож початок тривоги і вибір події не залежать від кількості регіонів
+func dedupeAlertTypes(alerts []Alert) []Alert {
+	index := make(map[string]int, len(alerts))
+	unique := make([]Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		i, ok := index[alert.Type]
+		if !ok {
+			index[alert.Type] = len(unique)
+			unique = append(unique, alert)
+			continue
+		}
+		if alert.LastUpdate < unique[i].LastUpdate {
+			unique[i] = alert
+		}
+	}
+	return unique
+}
+
+// Обчислює хеш ситуації: набір типів подій та час останнього оновлення
+func responseHash(alerts []Alert, lastUpdate string) string {
+	types := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		types = append(types, alert.Type+"@"+alert.LastUpdate)
+	}
+	sort.Strings(types)
+	sum := sha256.Sum256([]byte(lastUpdate + "|" + strings.Join(types, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Перевіряє, чи така сама відповідь вже була оброблена до перезапуску
+func isAlreadyProcessed(state *State, config *Config, hash string) bool {
+	if config.RestartDedupMin <= 0 || state.ResponseHash != hash {
+		return false
+	}
+	return time.Since(state.ProcessedAt) <= time.Duration(config.RestartDedupMin)*time.Minute
+}
+
+// Запамʼятовує оброблену відповідь для restart_dedup_min. Повертає true, якщо стан треба
+// зберегти: відповідь змінилась або збережений час обробки старший за половину вікна
+func rememberProcessed(state *State, config *Config, hash string) bool {
+	if config.RestartDedupMin <= 0 {
+		return false
+	}
+	window := time.Duration(config.RestartDedupMin) * time.Minute
+	if state.ResponseHash == hash && time.Since(state.ProcessedAt) < window/2 {
+		return false
+	}
+	state.ResponseHash = hash
+	state.ProcessedAt = time.Now().UTC()
+	return true
+}
+
+// Оновлює кеш відповіді. Повертає true, якщо кеш треба зберегти: відповідь змінилась
+// або збережена копія старша за половину cache_ttl_sec і інакше застаріла б на диску
+func updateResponseCache(state *State, config *Config, alerts []Alert, lastUpdate string) bool {
+	if !config.EnableCache {
+		return false
+	}
+	if cache := state.Cache; cache != nil && cache.LastUpdate == lastUpdate && alertsEqual(cache.Alerts, alerts) {
+		ttl := time.Duration(config.CacheTTLSec) * time.Second
+		if ttl <= 0 || time.Since(cache.FetchedAt) < ttl/2 {
+			return false
+		}
+	}
+	state.Cache = &ResponseCache{
+		Alerts:     alerts,
+		LastUpdate: lastUpdate,
+		FetchedAt:  time.Now().UTC(),
+	}
+	return true
+}
+
+// Порівнює списки подій разом з рівнем і регіоном
+func alertsEqual(a, b []Alert) bool {
+	return slices.EqualFunc(a, b, func(x, y Alert) bool {
+		if x.Type != y.Type || x.LastUpdate != y.LastUpdate || x.Region != y.Region {
+			return false
+		}
+		if x.Level == nil || y.Level == nil {
+			return x.Level == y.Level
+		}
+		return *x.Level == *y.Level
+	})
+}
+
+// Повертає кешовану відповідь, якщо кеш увімкнено і він не застарів
+func cachedResponse(state *State, config *Config) ([]Alert, string, bool) {
+	if !config.EnableCache || state.Cache == nil {
+		return nil, "", false
+	}
+	if config.CacheTTLSec > 0 && time.Since(state.Cache.FetchedAt) > time.Duration(config.CacheTTLSec)*time.Second {
+		log.Printf("Кешована відповідь від %s застаріла", state.Cache.FetchedAt.Format(time.RFC3339))
+		return nil, "", false
+	}
+	return state.Cache.Alerts, state.Cache.LastUpdate, true
+}
+
+// Повертає тривалість буфера аудіо
+func audioBufferDuration(config *Config) time.Duration {
+	if config.AudioBufferMs <= 0 {
+		return time.Second / 10 // Значення за замовчуванням
+	}
+	return time.Duration(config.AudioBufferMs) * time.Millisecond
+}
+
+func initSpeaker(config *Config, sampleRate beep.SampleRate) error {
+	selectAudioDevice(config)
+	return speaker.Init(sampleRate, sampleRate.N(audioBufferDuration(config)))
+}
+
+// Частота дискретизації, з якою ініціалізовано динамік. 0 - аудіо недоступне
+var speakerRate beep.SampleRate
+
+// Ініціалізує динамік один раз при запуску. Файли з іншою частотою дискретизації перетворюються до неї
+func initAudio(config *Config) error {
+	rate := beep.SampleRate(config.AudioSampleRate)
+	if rate <= 0 {
+		rate = 44100 // Значення за замовчуванням
+	}
+	if err := initSpeaker(config, rate); err != nil {
+		return err
+	}
+	speakerRate = rate
+	log.Printf("Аудіо: пристрій %s, частота %d Гц, буфер %s", audioDeviceName(config), rate, audioBufferDuration(config))
+	return nil
+}
+
+// Відкриває та декодує аудіофайл
+func decodeAudio(path string) (beep.StreamSeekCloser, beep.Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, beep.Format{}, fmt.Errorf("помилка відкриття аудіофайлу: %w", err)
+	}
+
+	// Формат визначається за розширенням файлу
+	var streamer beep.StreamSeekCloser
+	var format beep.Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		streamer, format, err = mp3.Decode(f)
+	case ".wav":
+		streamer, format, err = wav.Decode(f)
+	case ".ogg":
+		streamer, format, err = vorbis.Decode(f)
+	default:
+		f.Close()
+		return nil, beep.Format{}, fmt.Errorf("непідтримуваний формат аудіофайлу %q, підтримуються mp3, wav та ogg", filepath.Ext(path))
+	}
+	if err != nil {
+		f.Close()
+		return nil, beep.Format{}, fmt.Errorf("помилка декодування аудіофайлу: %w", err)
+	}
+	return streamer, format, nil
+}
+
+// Повертає всі аудіофайли, вказані у конфігурації
+func configuredAudioFiles(config *Config) []string {
+	paths := []string{config.AlertOnEmpty, config.RepeatAudioFile, config.AttentionTone, config.StillActiveChime, config.DeescalationAudio}
+	for _, files := range config.AudioFiles {
+		paths = append(paths, files...)
+	}
+	for _, path := range config.RepeatAudioFiles {
+		paths = append(paths, path)
+	}
+	for _, path := range config.TransitionAudio {
+		paths = append(paths, path)
+	}
+	for _, steps := range config.Escalation {
+		for _, step := range steps {
+			paths = append(paths, step.AudioFile)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, path := range paths {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Перевіряє, що всі аудіофайли існують і декодуються. Повертає одну помилку з переліком усіх проблем
+func validateAudioFiles(config *Config) error {
+	var errs []error
+	for _, path := range configuredAudioFiles(config) {
+		streamer, _, err := decodeAudio(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		streamer.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// Playlist - один або кілька аудіофайлів події. У конфігурації задається рядком або масивом рядків
+type Playlist []string
+
+func (l *Playlist) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*l = Playlist{path}
+		return nil
+	}
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return err
+	}
+	*l = paths
+	return nil
+}
+
+// Відтворює файли по черзі, кожен до кінця. Переривання зупиняє і решту файлів
+func playPlaylist(config *Config, files Playlist) {
+	if len(files) == 0 {
+		log.Println("Список аудіофайлів порожній")
+		return
+	}
+	for _, path := range files {
+		playAudio(config, path)
+	}
+}
+
+// Повторює відкриття та декодування до audio_retries разів, якщо файл тимчасово недоступний
+func decodeWithRetry(config *Config, path string, decode func(string) (beep.StreamSeekCloser, beep.Format, error)) (beep.StreamSeekCloser, beep.Format, error) {
+	attempts := max(config.AudioRetries, 1)
+	retryDelay := time.Duration(config.AudioRetryDelayMs) * time.Millisecond
+	if retryDelay <= 0 {
+		retryDelay = 500 * time.Millisecond // Значення за замовчуванням
+	}
+
+	var streamer beep.StreamSeekCloser
+	var format beep.Format
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		streamer, format, err = decode(path)
+		if err == nil {
+			break
+		}
+		if attempt < attempts {
+			logWarn("Спроба %d з %d: %v, повтор через %s", attempt, attempts, err, retryDelay)
+			time.Sleep(retryDelay)
+		}
+	}
+	return streamer, format, err
+}
+
+func playAudio(config *Config, path string) {
+	if path == "" {
+		log.Println("Аудіофайл не вказано")
+		return
+	}
+
+	streamer, format, err := decodeWithRetry(config, path, decodeAudio)
+	if err != nil {
+		logError("Помилка відтворення %s: %v", path, err)
+		return
+	}
+	defer streamer.Close()
+
+	if speakerRate == 0 {
+		log.Printf("Аудіо недоступне, звук %s не відтворено", path)
+		return
+	}
+
+	// Перетворюємо частоту дискретизації файлу до частоти динаміка
+	var stream beep.Streamer = streamer
+	if format.SampleRate != speakerRate {
+		stream = beep.Resample(4, format.SampleRate, speakerRate, streamer)
+	}
+
+	if !playback.play(applyGain(config, path, stream)) {
+		log.Printf("Відтворення %s перервано", path)
+	}
+}
+
+// Відтворює по черзі звуки подій, відбою та повторного сигналу. Якщо вказано тип події - лише його звук
+func playTestAudio(config *Config, alertType string) {
+	log.Printf("Перевірка звуку, пристрій виводу: %s", audioDeviceName(config))
+	if alertType != "" {
+		files, ok := config.AudioFiles[alertType]
+		if !ok {
+			log.Printf("Для події %s аудіофайл не вказано", alertType)
+			return
+		}
+		log.Printf("Відтворення звуку події %s: %s", alertType, strings.Join(files, ", "))
+		playPlaylist(config, files)
+		return
+	}
+
+	types := make([]string, 0, len(config.AudioFiles))
+	for alertType := range config.AudioFiles {
+		types = append(types, alertType)
+	}
+	sort.Strings(types)
+	for _, alertType := range types {
+		log.Printf("Відтворення звуку події %s: %s", alertType, strings.Join(config.AudioFiles[alertType], ", "))
+		playPlaylist(config, config.AudioFiles[alertType])
+	}
+	if config.AlertOnEmpty != "" {
+		log.Printf("Відтворення звуку відбою: %s", config.AlertOnEmpty)
+		playAudio(config, config.AlertOnEmpty)
+	}
+	if config.RepeatAudioFile != "" {
+		log.Printf("Відтворення повторного сигналу: %s", config.RepeatAudioFile)
+		playAudio(config, config.RepeatAudioFile)
+	}
+	log.Println("Перевірку звуків завершено")
+}
+
+// Параметри синтезованого сигналу уваги (висхідний тон)
+const (
+	attentionDuration = time.Second
+	attentionStartHz  = 500.0
+	attentionEndHz    = 1500.0
+	attentionGain     = 0.5
+)
+
+func playAttentionTone(config *Config) {
+	if !config.EnableAttention {
+		return
+	}
+
+	// Якщо файл вказано, відтворюємо його, інакше синтезуємо висхідний тон
+	if config.AttentionTone != "" {
+		playAudio(config, config.AttentionTone)
+		return
+	}
+
+	if speakerRate == 0 {
+		log.Println("Аудіо недоступне, сигнал уваги не відтворено")
+		return
+	}
+
+	total := speakerRate.N(attentionDuration)
+	pos := 0
+	phase := 0.0
+	sweep := beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		if pos >= total {
+			return 0, false
+		}
+		for i := range samples {
+			if pos >= total {
+				return i, true
+			}
+			freq := attentionStartHz + (attentionEndHz-attentionStartHz)*float64(pos)/float64(total)
+			phase += 2 * math.Pi * freq / float64(speakerRate)
+			value := attentionGain * math.Sin(phase)
+			samples[i][0], samples[i][1] = value, value
+			pos++
+		}
+		return len(samples), true
+	})
+
+	playback.play(applyGain(config, "", sweep))
+}
+
+// Межі підсилення, щоб уникнути спотворення звуку
+const (
+	minGain = 0.0
+	maxGain = 2.0
+)
+
+// Розраховує підсилення для файлу: загальна гучність volume, помножена на підсилення файлу з audio_gains
+func audioGain(config *Config, path string) float64 {
+	gain := 1.0
+	if config.Volume != nil {
+		gain = *config.Volume
+	}
+	if fileGain, ok := config.AudioGains[path]; ok && path != "" {
+		gain *= fileGain
+	}
+	return math.Max(minGain, math.Min(gain, maxGain))
+}
+
+// Застосовує підсилення до потоку. Гучність у beep задається степенем двійки
+func applyGain(config *Config, path string, streamer beep.Streamer) beep.Streamer {
+	gain := audioGain(config, path)
+	if gain == 1 {
+		return streamer
+	}
+	if config.Debug {
+		logDebug("Підсилення звуку %s: %.2f", path, gain)
+	}
+	return &effects.Volume{
+		Streamer: streamer,
+		Base:     2,
+		Volume:   math.Log2(gain),
+		Silent:   gain == 0,
+	}
+}
+
+// Поточна версія формату state.json
+const stateVersion = 2
+
+func loadState(path string) (*State, error) {
+	data, err := os.ReadFile(path) // Заміщено ioutil.ReadFile на os.ReadFile
+	if err != nil {
+		if os.IsNotExist(err) {
+			state := &State{}
+			migrateState(state)
+			return state, nil
+		}
+		return nil, err
+	}
+	var state State
+	err = json.Unmarshal(data, &state)
+	migrateState(&state)
+	return &state, err
+}
+
+// Оновлює стан, збережений попередніми версіями програми, до поточного формату
+func migrateState(state *State) {
+	// Файли без версії мають формат 1
+	if state.Version == 0 {
+		state.Version = 1
+	}
+	if state.Version > stateVersion {
+		logWarn("УВАГА: файл стану має версію %d, новішу за підтримувану (%d). Невідомі поля буде втрачено при збереженні", state.Version, stateVersion)
+	}
+
+	// 1 -> 2: додано кеш відповіді та хеш обробленої відповіді, значення за замовчуванням порожні
+	if state.Version < 2 {
+		state.Version = 2
+	}
+
+	// Ініціалізуємо порожні карти
+	if state.ActiveAlertTypes == nil {
+		state.ActiveAlertTypes = make(map[string]bool)
+	}
+	if state.LastPlayed == nil {
+		state.LastPlayed = make(map[string]time.Time)
+	}
+	if state.LastChime == nil {
+		state.LastChime = make(map[string]time.Time)
+	}
+	if state.ActiveSince == nil {
+		state.ActiveSince = make(map[string]time.Time)
+	}
+	if state.DailyStats == nil {
+		state.DailyStats = make(map[string]*AlertStats)
+	}
+	if state.AlertRegions == nil {
+		state.AlertRegions = make(map[string]string)
+	}
+}
+
+// Зберігає стан у файл. Викликається під блокуванням state.mu, сам стан не змінює,
+// тому інші горутини можуть безпечно читати його через Snapshot
+func saveState(state *State, path string) {
+	// Порожня карта LastPlayed зберігається як null
+	var lastPlayed map[string]time.Time
+	if len(state.LastPlayed) > 0 {
+		lastPlayed = state.LastPlayed
+	}
+
+	data, err := json.Marshal(struct {
+		Version int `json:"version"`
+		*State
+		LastPlayed map[string]time.Time `json:"last_played"`
+	}{stateVersion, state, lastPlayed})
+	if err != nil {
+		logError("Помилка збереження стану: %v", err)
+		return
+	}
+	err = writeFileAtomic(path, data, 0644)
+	if err != nil {
+		logError("Помилка запису стану у файл: %v", err)
+	}
+}
+
+// Записує файл через тимчасовий файл у тому ж каталозі та перейменування,
+// щоб аварійне завершення не залишило обрізаний файл
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// Налаштовує логування. Повертає відкритий файл логу, якщо логування у файл увімкнено
+func setupLogging(config *Config) io.Closer {
+	// Назва екземпляра за замовчуванням - імʼя хоста
+	if config.InstanceName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "signal"
+		}
+		config.InstanceName = hostname
+	}
+	log.SetPrefix(fmt.Sprintf("[%s %s] ", config.InstanceName, runID))
+
+	// Рівні перевіряються у validateConfig
+	stdoutLevel, _ := parseLogLevel(config.StdoutLogLevel)
+	fileLevel, _ := parseLogLevel(config.FileLogLevel)
+	out := &logOutput{sinks: []logSink{{out: os.Stdout, level: stdoutLevel}}}
+	var logFile *rotatingWriter
+	if config.LogToFile {
+		var err error
+		logFile, err = openRotatingWriter(config.LogFilePath, config.LogMaxSizeMB, config.LogMaxBackups)
+		if err != nil {
+			logFatal("Помилка відкриття файлу логу: %v", err)
+		}
+		out.sinks = append(out.sinks, logSink{out: logFile, level: fileLevel})
+	}
+
+	if config.LogFormat == "json" {
+		setupJSONLogging(out, config)
+	} else {
+		log.SetOutput(leveledWriter{out: out, level: slog.LevelInfo})
+		logOut = out
+	}
+	if logFile == nil {
+		return nil
+	}
+	return logFile
 }
 
 func convertToLocalTime(utcTime string, timeZone string) string {
 	// Перетворюємо час UTC у локальну часову зону
 	location, err := time.LoadLocation(timeZone)
 	if err != nil {
-		log.Printf("Помилка завантаження часової зони: %v", err)
+		logError("Помилка завантаження часової зони: %v", err)
 		return utcTime // Повертаємо UTC, якщо часова зона недоступна
 	}
-	parsedTime, err := time.Parse(time.RFC3339, utcTime)
+	parsedTime, err := parseAPITime(utcTime)
 	if err != nil {
-		log.Printf("Помилка парсингу часу у форматі RFC3339: %v", err)
+		logError("Помилка парсингу часу: %v", err)
 		return utcTime
 	}
 	return parsedTime.In(location).Format("2006-01-02 15:04:05")
 }
 
-func checkAndHandleStateChange(state *State, currentAlerts map[string]bool, alerts []Alert, lastUpdate string, config *Config, statePath string) {
-	// Перевіряємо нові події
-	var selectedAlert *Alert
+func checkAndHandleStateChange(state *State, currentAlerts map[string]bool, alerts []Alert, lastUpdate string, config *Config) []AlertEvent {
+	var events []AlertEvent
+	previous := activeAlertTypes(state)
+
+	// Термінові події обробляються одразу, без вибору пріоритетної події та будь-якого згладжування
 	for _, alert := range alerts {
-		if alert.Type == "AIR" {
-			selectedAlert = &alert
-			break
+		if isImmediateType(config, alert.Type) && !state.ActiveAlertTypes[alert.Type] {
+			started := alertStartTime(alert, time.Now().UTC())
+			state.ActiveAlertTypes[alert.Type] = true
+			state.LastPlayed[alert.Type] = time.Now().UTC()
+			state.ActiveSince[alert.Type] = started
+			setAlertRegion(state, alert.Type, alert.Region)
+			logEvent(slog.LevelInfo, fmt.Sprintf("Термінова подія увімкнено: %s, час: %s", alert.Type, alert.LastUpdate),
+				"event", "alert_on", "alert_type", alert.Type, "last_update", alert.LastUpdate, "immediate", true)
+			events = append(events, AlertEvent{Kind: "start", AlertType: alert.Type, Time: alert.LastUpdate, Immediate: true, Region: alert.Region})
 		}
 	}
 
-	// Якщо події з type: AIR немає, вибираємо найраніше за lastUpdate
-	if selectedAlert == nil && len(alerts) > 0 {
-		selectedAlert = &alerts[0]
-		for _, alert := range alerts {
-			if alert.LastUpdate < selectedAlert.LastUpdate {
-				selectedAlert = &alert
-			}
-		}
+	// Перевіряємо нові події
+	var selectedAlert *Alert
+	if config.UseServerPriority {
+		selectedAlert = selectByServerLevel(alerts)
+	}
+	if selectedAlert == nil {
+		selectedAlert = selectPrimaryAlert(alerts, config)
 	}
 
 	// Якщо вибрано подію, обробляємо її
@@ -351,82 +2168,462 @@ func checkAndHandleStateChange(state *State, currentAlerts map[string]bool, aler
 		alertType := selectedAlert.Type
 		if !state.ActiveAlertTypes[alertType] {
 			// Нова подія — зберігаємо стан і відтворюємо звук початку події
+			started := alertStartTime(*selectedAlert, time.Now().UTC())
 			state.ActiveAlertTypes[alertType] = true
-			state.LastPlayed[alertType] = time.Now().UTC() // Встановлюємо поточний час для події
-			saveState(state, statePath)
-			log.Printf("Подія увімкнено: %s, час: %s", alertType, selectedAlert.LastUpdate)
-			playAudio(config.AudioFiles[alertType])
+			state.LastPlayed[alertType] = time.Now().UTC() // Звук початку лунає зараз, повтор - через повний інтервал
+			state.ActiveSince[alertType] = started
+			setAlertRegion(state, alertType, selectedAlert.Region)
+			logEvent(slog.LevelInfo, fmt.Sprintf("Подія увімкнено: %s, час: %s", alertType, selectedAlert.LastUpdate),
+				"event", "alert_on", "alert_type", alertType, "last_update", selectedAlert.LastUpdate)
+			events = append(events, AlertEvent{Kind: "start", AlertType: alertType, Time: selectedAlert.LastUpdate, Region: selectedAlert.Region})
 		}
 	}
 
 	// Логуємо стан активних подій
-	for alertType := range state.ActiveAlertTypes {
+	for _, alertType := range presentedAlertTypes(state, config, time.Now()) {
 		localTime := convertToLocalTime(lastUpdate, config.TimeZone)
 		log.Printf("Триває тривога від %s для події: %s", localTime, alertType)
 	}
 
 	// Перевіряємо зниклі події
+	activeBefore := len(state.ActiveAlertTypes)
 	for alertType := range state.ActiveAlertTypes {
+		if !pendingOffElapsed(state, config, alertType, currentAlerts[alertType], time.Now()) {
+			continue
+		}
 		if !currentAlerts[alertType] {
 			// Подія зникла — зберігаємо стан і відтворюємо звук закінчення події
 			delete(state.ActiveAlertTypes, alertType)
-			saveState(state, statePath)
-			log.Printf("Подія вимкнено: %s, час завершення: %s", alertType, lastUpdate)
-			playAudio(config.AlertOnEmpty)
+			delete(state.LastPlayed, alertType) // Відлік повторів нової тривоги почнеться з її початку
+			delete(state.LastChime, alertType)
+			region := state.AlertRegions[alertType]
+			delete(state.AlertRegions, alertType)
+			var duration time.Duration
+			if since, ok := state.ActiveSince[alertType]; ok {
+				duration = time.Since(since)
+				recordAlertStats(state, alertType, duration)
+				delete(state.ActiveSince, alertType)
+			}
+			// Якщо програму запущено посеред тривоги без збереженого стану, початок невідомий
+			lasted := "невідомо"
+			if duration > 0 {
+				lasted = formatDuration(duration)
+			}
+			logEvent(slog.LevelInfo, fmt.Sprintf("Подія вимкнено: %s, час завершення: %s, тривала %s", alertType, lastUpdate, lasted),
+				"event", "alert_off", "alert_type", alertType, "last_update", lastUpdate, "duration_sec", int64(duration.Seconds()))
+			events = append(events, AlertEvent{Kind: "end", AlertType: alertType, Time: lastUpdate, Duration: duration, Region: region})
+		}
+	}
+
+	// Кількість активних подій зменшилась, але не до нуля
+	remaining := len(state.ActiveAlertTypes)
+	if remaining > 0 && remaining < activeBefore {
+		for i := range events {
+			if events[i].Kind == "end" {
+				events[i].Remaining = remaining
+			}
+		}
+		if config.EnableDeescalation {
+			log.Printf("Кількість активних подій зменшилась: %d -> %d", activeBefore, remaining)
+			events = append(events, AlertEvent{Kind: "deescalation", Time: lastUpdate, Remaining: remaining})
 		}
 	}
+
+	applyTransitions(events, previous, config)
+	markAllClear(events, activeAlertTypes(state), config)
+	return events
 }
 
-func checkAndPlayRepeatAudio(state *State, config *Config, location *time.Location, statePath string) {
-	if !config.EnableRepeatAudio || config.RepeatAudioFile == "" || config.RepeatIntervalMin <= 0 {
-		return // Виходимо, якщо повторюваний сигнал вимкнено або параметри некоректні
+// Запамʼятовує регіон активної події, щоб передати його і у подію закінчення
+func setAlertRegion(state *State, alertType, region string) {
+	if region == "" {
+		return
+	}
+	if state.AlertRegions == nil {
+		state.AlertRegions = make(map[string]string)
 	}
+	state.AlertRegions[alertType] = region
+}
 
-	// Вибираємо подію для відтворення повторного звуку
-	var selectedAlertType string
-	for alertType := range state.ActiveAlertTypes {
-		if selectedAlertType == "" || alertType == "AIR" {
-			selectedAlertType = alertType
+// Час початку події з її lastUpdate, щоб після перезапуску посеред тривоги тривалість
+// і повтори рахувались від справжнього початку. Якщо час не розібрано або він у майбутньому - now
+func alertStartTime(alert Alert, now time.Time) time.Time {
+	started, err := parseAPITime(alert.LastUpdate)
+	if err != nil {
+		if alert.LastUpdate != "" {
+			logError("Помилка парсингу часу події %s: %v, початком вважається поточний час", alert.Type, err)
 		}
+		return now
 	}
+	if started.After(now) {
+		return now
+	}
+	return started.UTC()
+}
 
-	// Перевіряємо, чи потрібно відтворити повторний звук для вибраної події
-	if selectedAlertType != "" {
-		lastUpdateTime, err := time.Parse(time.RFC3339, state.LastUpdate)
+// Визначає, чи вважати активну подію завершеною. Якщо подія зникла з відповіді менше ніж на
+// alert_off_cooldown_sec, відбій відкладається, а якщо вона зʼявилась знову - тихо скасовується
+func pendingOffElapsed(state *State, config *Config, alertType string, present bool, now time.Time) bool {
+	if state.PendingOff == nil {
+		state.PendingOff = make(map[string]time.Time)
+	}
+	if present {
+		if _, ok := state.PendingOff[alertType]; ok {
+			delete(state.PendingOff, alertType)
+			if config.Debug {
+				logDebug("Подія %s знову у відповіді, відбій скасовано", alertType)
+			}
+		}
+		return true
+	}
+	if config.AlertOffCooldown <= 0 {
+		return true
+	}
+
+	since, ok := state.PendingOff[alertType]
+	if !ok {
+		state.PendingOff[alertType] = now
+		log.Printf("Подія %s зникла з відповіді, відбій через %d с, якщо вона не зʼявиться знову", alertType, config.AlertOffCooldown)
+		return false
+	}
+	if now.Sub(since) < time.Duration(config.AlertOffCooldown)*time.Second {
+		return false
+	}
+	delete(state.PendingOff, alertType)
+	return true
+}
+
+// Встановлює початковий час відліку повторів для події, активної при запуску:
+// now (за замовчуванням) - чекати повний інтервал, epoch - повтор одразу, from_server - від часу події на сервері
+func initStartupLastPlayed(state *State, config *Config, alertType string, serverTime string, now time.Time) {
+	switch config.StartupLastPlayed {
+	case "epoch":
+		state.LastPlayed[alertType] = time.Unix(0, 0).UTC()
+	case "from_server":
+		serverStart, err := parseAPITime(serverTime)
 		if err != nil {
-			log.Printf("Помилка парсингу часу last_update: %v", err)
+			logError("Помилка парсингу часу події %s: %v, відлік повторів від поточного часу", alertType, err)
+			state.LastPlayed[alertType] = now
+			return
+		}
+		state.LastPlayed[alertType] = serverStart.UTC()
+	default:
+		state.LastPlayed[alertType] = now
+	}
+}
+
+// Вибирає подію з найвищим рівнем від провайдера, при рівних - найранішу.
+// Повертає nil, якщо жодна подія не має рівня
+func selectByServerLevel(alerts []Alert) *Alert {
+	var selected *Alert
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.Level == nil {
+			continue
+		}
+		if selected == nil || *alert.Level > *selected.Level ||
+			(*alert.Level == *selected.Level && alert.LastUpdate < selected.LastUpdate) {
+			selected = alert
+		}
+	}
+	return selected
+}
+
+// Порядок типів подій від найважливішого (alert_priority)
+func alertPriorityOrder(config *Config) []string {
+	if len(config.AlertPriority) == 0 {
+		return []string{"AIR"} // Значення за замовчуванням
+	}
+	return config.AlertPriority
+}
+
+// Місце типу події у alert_priority, len(alert_priority) - тип відсутній у списку
+func alertTypeRank(config *Config, alertType string) int {
+	order := alertPriorityOrder(config)
+	for i, listed := range order {
+		if listed == alertType {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// Вибирає головну подію: тип, що стоїть раніше у alert_priority, а серед рівних
+// (або відсутніх у списку) - найранішу за lastUpdate. Повертає nil, якщо подій немає
+func selectPrimaryAlert(active []Alert, config *Config) *Alert {
+	var selected *Alert
+	selectedRank := 0
+	// Беремо адресу елемента зрізу, а не змінної циклу
+	for i := range active {
+		rank := alertTypeRank(config, active[i].Type)
+		if selected == nil || rank < selectedRank ||
+			(rank == selectedRank && active[i].LastUpdate < selected.LastUpdate) {
+			selected, selectedRank = &active[i], rank
+		}
+	}
+	return selected
+}
+
+// Вибирає подію для повторного сигналу та сигналу "тривога ще триває" за тими ж правилами,
+// що й головну подію, без застарілих подій. Час події - її початок з ActiveSince
+func repeatAlertType(state *State, config *Config) string {
+	active := make([]Alert, 0, len(state.ActiveAlertTypes))
+	for _, alertType := range activeAlertTypes(state) {
+		if state.Expired[alertType] {
+			continue
+		}
+		alert := Alert{Type: alertType}
+		if since, ok := state.ActiveSince[alertType]; ok {
+			alert.LastUpdate = since.UTC().Format(time.RFC3339)
+		}
+		active = append(active, alert)
+	}
+	if selected := selectPrimaryAlert(active, config); selected != nil {
+		return selected.Type
+	}
+	return ""
+}
+
+// Чи лунає звук відбою для закінчення події. Якщо вказано all_clear_types, відбій лунає лише коли
+// закінчується остання активна подія зі списку, закінчення інших подій лише записується у лог
+func allClearReached(config *Config, alertType string, active []string) bool {
+	if len(config.AllClearTypes) == 0 {
+		return true
+	}
+	if !slices.Contains(config.AllClearTypes, alertType) {
+		return false
+	}
+	for _, activeType := range active {
+		if slices.Contains(config.AllClearTypes, activeType) {
+			return false
+		}
+	}
+	return true
+}
+
+// Позначає звук відбою для останньої події закінчення, після якої він має лунати.
+// Якщо кілька подій закінчились за одне опитування, відбій лунає один раз
+func markAllClear(events []AlertEvent, active []string, config *Config) {
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if event.Kind != "end" || event.Handoff != "" {
+			continue
+		}
+		// Замість відбою лунає сигнал покращення ситуації
+		if config.EnableDeescalation && event.Remaining > 0 {
+			continue
+		}
+		if allClearReached(config, event.AlertType, active) {
+			events[i].AllClear = true
 			return
 		}
+	}
+}
+
+// Визначає, чи відтворювати звуки для типу події. Порожній білий список дозволяє всі типи
+func audioAllowed(config *Config, alertType string) bool {
+	for _, blocked := range config.AudioBlacklist {
+		if blocked == alertType {
+			return false
+		}
+	}
+	if len(config.AudioWhitelist) == 0 {
+		return true
+	}
+	for _, allowed := range config.AudioWhitelist {
+		if allowed == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+func isImmediateType(config *Config, alertType string) bool {
+	for _, immediate := range config.ImmediateTypes {
+		if immediate == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// Повертає відсортований список активних подій
+func activeAlertTypes(state *State) []string {
+	types := make([]string, 0, len(state.ActiveAlertTypes))
+	for alertType := range state.ActiveAlertTypes {
+		types = append(types, alertType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func updateSeenSince(state *State, currentAlerts map[string]bool, now time.Time) {
+	if state.SeenSince == nil {
+		state.SeenSince = make(map[string]time.Time)
+	}
+	for alertType := range currentAlerts {
+		if _, ok := state.SeenSince[alertType]; !ok {
+			state.SeenSince[alertType] = now
+		}
+	}
+	for alertType := range state.SeenSince {
+		if !currentAlerts[alertType] {
+			delete(state.SeenSince, alertType)
+		}
+	}
+}
+
+// Повертає активні події для відображення. Подія показується лише після того, як вона
+// присутня не менше ui_smoothing_sec. Це згладжування не впливає на відтворення звуків
+func presentedAlertTypes(state *State, config *Config, now time.Time) []string {
+	smoothing := time.Duration(config.UISmoothingSec) * time.Second
+	var types []string
+	for alertType := range state.ActiveAlertTypes {
+		if smoothing > 0 && !isImmediateType(config, alertType) {
+			since, ok := state.SeenSince[alertType]
+			if !ok || now.Sub(since) < smoothing {
+				continue
+			}
+		}
+		types = append(types, alertType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Інтервал повторного звуку для типу події, за замовчуванням repeat_interval_min
+func repeatIntervalFor(config *Config, alertType string) int {
+	if interval, ok := config.RepeatIntervals[alertType]; ok {
+		return interval
+	}
+	return config.RepeatIntervalMin
+}
+
+// Файл повторного звуку для типу події, за замовчуванням repeat_audio_file
+func repeatAudioFor(config *Config, alertType string) string {
+	if file, ok := config.RepeatAudioFiles[alertType]; ok && file != "" {
+		return file
+	}
+	return config.RepeatAudioFile
+}
+
+func checkAndPlayRepeatAudio(state *State, config *Config, location *time.Location, statePath string) []AlertEvent {
+	if !config.EnableRepeatAudio {
+		return nil // Виходимо, якщо повторюваний сигнал вимкнено
+	}
+	if snooze.snoozed(config, time.Now()) {
+		return nil // Повторні сигнали тимчасово вимкнено користувачем
+	}
+
+	// Вибираємо подію для відтворення повторного звуку
+	selectedAlertType := repeatAlertType(state, config)
 
+	// Перевіряємо, чи потрібно відтворити повторний звук для вибраної події
+	if selectedAlertType != "" {
+		// Відлік від останнього відтворення, тому повтор не залежить від частоти запитів
 		now := time.Now().UTC()
-		elapsedMinutes := int(now.Sub(lastUpdateTime).Minutes())
+		var elapsed time.Duration
+		if since, ok := state.ActiveSince[selectedAlertType]; ok {
+			elapsed = now.Sub(since)
+		}
+		interval := repeatIntervalFor(config, selectedAlertType)
+		audio := repeatAudioAt(config, selectedAlertType, elapsed)
+		if interval <= 0 || audio == "" {
+			return nil // Для цього типу повтор вимкнено або параметри некоректні
+		}
+
+		lastPlayed, ok := state.LastPlayed[selectedAlertType]
+		if !ok {
+			state.LastPlayed[selectedAlertType] = now
+			return nil
+		}
 
-		// Розраховуємо, чи має відтворюватися повторна подія
-		if elapsedMinutes >= config.RepeatIntervalMin && elapsedMinutes%config.RepeatIntervalMin == 0 {
-			log.Printf("Відтворення повторного звуку для події: %s", selectedAlertType)
-			playAudio(config.RepeatAudioFile)
+		if now.Sub(lastPlayed) >= time.Duration(interval)*time.Minute {
+			state.LastPlayed[selectedAlertType] = now
+			logEvent(slog.LevelInfo, fmt.Sprintf("Відтворення повторного звуку для події: %s", selectedAlertType),
+				"event", "repeat", "alert_type", selectedAlertType, "last_update", state.LastUpdate)
+			return []AlertEvent{{Kind: "repeat", AlertType: selectedAlertType, Time: state.LastUpdate, Audio: audio}}
 		}
 	}
+	return nil
 }
 
-func printConfigDescription() {
-	fmt.Println("Опис файлу конфігурації:")
-	fmt.Println(`
-{
-  "api_url": "URL для API запитів",
-  "auth_header": "Заголовок авторизації для API",
+// Тихий сигнал "тривога ще триває" зі своїм інтервалом, незалежним від повторного звуку
+func checkStillActiveChime(state *State, config *Config, now time.Time) []AlertEvent {
+	if config.StillActiveChime == "" || config.StillActiveMin <= 0 {
+		return nil
+	}
+
+	// Вибираємо подію так само, як для повторного звуку
+	selectedAlertType := repeatAlertType(state, config)
+	if selectedAlertType == "" {
+		return nil
+	}
+
+	// Відлік від останнього сигналу, а якщо його не було - від початку тривоги
+	since, ok := state.LastChime[selectedAlertType]
+	if !ok {
+		since, ok = state.ActiveSince[selectedAlertType]
+	}
+	if !ok {
+		state.LastChime[selectedAlertType] = now
+		return nil
+	}
+
+	if now.Sub(since) < time.Duration(config.StillActiveMin)*time.Minute {
+		return nil
+	}
+	state.LastChime[selectedAlertType] = now
+	log.Printf("Відтворення сигналу що тривога ще триває для події: %s", selectedAlertType)
+	return []AlertEvent{{Kind: "chime", AlertType: selectedAlertType, Time: state.LastUpdate}}
+}
+
+// Шаблон конфігурації з коментарями. Після видалення коментарів це коректний JSON
+const configTemplate = `{
+  // URL для API запитів, CODE - код регіону
+  "api_url": "https://api.ukrainealarm.com/api/v3/alerts/CODE",
+  // Заголовок авторизації для API. Можна вказати змінну середовища: "${SIGNAL_TOKEN}"
+  "auth_header": "place your token here",
+  // Аудіофайли для типів подій: один файл або масив файлів
   "audio_files": {
-    "AIR": "Шлях до аудіофайлу для події AIR",
-    "FIRE": "Шлях до аудіофайлу для події FIRE"
+    "AIR": "sounds/air_1.mp3",
+    "ARTILLERY": "sounds/air_alert.mp3",
+    "URBAN_FIGHTS": "sounds/air_alert.mp3",
+    "CHEMICAL": "sounds/air_alert.mp3",
+    "NUCLEAR": "sounds/air_alert.mp3",
+    "UNKNOWN": "sounds/air_alert.mp3"
   },
-  "alert_on_empty": "Шлях до аудіофайлу для події, коли масив порожній",
-  "debug": true, // Увімкнення режиму налагодження
-  "log_to_file": true, // Увімкнення дублювання логу у файл
-  "log_file_path": "Шлях до файлу логу",
-  "time_zone": "Локальна часова зона, наприклад, Europe/Kiev",
-  "repeat_audio_file": "Шлях до аудіофайлу для повторного відтворення",
-  "repeat_interval_min": 10 // Інтервал повторного відтворення у хвилинах
-  "request_interval_sec": 30 // Інтервал запитів до сервера у секундах
-}
-    `)
+  // Аудіофайл відбою, коли активних подій не залишилось
+  "alert_on_empty": "sounds/air_2.mp3",
+  // Увімкнення режиму налагодження
+  "debug": false,
+  // Увімкнення дублювання логу у файл
+  "log_to_file": true,
+  // Шлях до файлу логу
+  "log_file_path": "app.log",
+  // Локальна часова зона
+  "time_zone": "Europe/Kiev",
+  // Увімкнення повторного сигналу, поки тривога триває
+  "enable_repeat_audio": true,
+  // Аудіофайл для повторного відтворення
+  "repeat_audio_file": "sounds/air_1.mp3",
+  // Інтервал повторного відтворення у хвилинах
+  "repeat_interval_min": 15,
+  // Інтервал запитів до сервера у секундах
+  "request_interval_sec": 30
+}
+`
+
+func printConfigDescription() {
+	fmt.Println("Опис файлу конфігурації (усі параметри описано у README.md):")
+	fmt.Println()
+	fmt.Print(configTemplate)
+}
+
+// Записує шаблон конфігурації у файл. Наявний файл перезаписується лише з force
+func writeConfigTemplate(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("файл %s вже існує, для перезапису додайте прапорець -force", path)
+	}
+	return os.WriteFile(path, []byte(configTemplate), 0644)
 }
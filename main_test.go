@@ -0,0 +1,1049 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// Створює обробник з порожнім станом, що зберігається у тимчасовий каталог
+func newTestEvaluator(t *testing.T, config *Config) *Evaluator {
+	t.Helper()
+	state := &State{}
+	migrateState(state)
+	return &Evaluator{
+		config:    config,
+		state:     state,
+		location:  time.UTC,
+		statePath: filepath.Join(t.TempDir(), "state.json"),
+		firstPoll: true,
+	}
+}
+
+func TestProcessSkipsWriteWhenRegionsUnchanged(t *testing.T) {
+	config := &Config{EnableCache: true, CacheTTLSec: 3600, RestartDedupMin: 10}
+	started := time.Now().UTC().Add(-10 * time.Minute).Format(time.RFC3339)
+	regions := func() []Region {
+		return []Region{
+			{RegionName: "Київ", LastUpdate: started, ActiveAlerts: []Alert{{Type: "AIR", LastUpdate: started, Region: "Київ"}}},
+			{RegionName: "Львів", LastUpdate: started, ActiveAlerts: []Alert{{Type: "ARTILLERY", LastUpdate: started, Region: "Львів"}}},
+			{RegionName: "Одеса", LastUpdate: started},
+		}
+	}
+	e := newTestEvaluator(t, config)
+
+	alerts, lastUpdate := aggregateRegions(regions())
+	if events := e.process(FetchResult{Alerts: alerts, LastUpdate: lastUpdate}); len(events) == 0 {
+		t.Fatal("first poll produced no events")
+	}
+	if _, err := os.Stat(e.statePath); err != nil {
+		t.Fatalf("first poll did not save state: %v", err)
+	}
+
+	// Кожна наступна відповідь розбирається заново, але нічого не змінює
+	writes := 0
+	for poll := 2; poll <= 5; poll++ {
+		os.Remove(e.statePath)
+		alerts, lastUpdate := aggregateRegions(regions())
+		if events := e.process(FetchResult{Alerts: alerts, LastUpdate: lastUpdate}); len(events) != 0 {
+			t.Errorf("poll %d: unexpected events %+v", poll, events)
+		}
+		if _, err := os.Stat(e.statePath); err == nil {
+			writes++
+		}
+	}
+	if writes != 0 {
+		t.Errorf("state.json written %d times across unchanged polls, want 0", writes)
+	}
+
+	// Зміна в одному регіоні зберігається одним записом
+	os.Remove(e.statePath)
+	changed := regions()
+	changed[2].ActiveAlerts = []Alert{{Type: "CHEMICAL", LastUpdate: started, Region: "Одеса"}}
+	alerts, lastUpdate = aggregateRegions(changed)
+	e.process(FetchResult{Alerts: alerts, LastUpdate: lastUpdate})
+	if _, err := os.Stat(e.statePath); err != nil {
+		t.Errorf("changed poll did not save state: %v", err)
+	}
+}
+
+func TestRestartWithCache(t *testing.T) {
+	config := &Config{EnableCache: true, CacheTTLSec: 3600}
+	started := time.Now().UTC().Add(-30 * time.Minute).Format(time.RFC3339)
+	e := newTestEvaluator(t, config)
+	e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started})
+
+	// Перезапуск: стан читається з файлу, сервер недоступний
+	state, err := loadState(e.statePath)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	alerts, lastUpdate, ok := cachedResponse(state, config)
+	if !ok || len(alerts) != 1 || alerts[0].Type != "AIR" || lastUpdate != started {
+		t.Fatalf("cachedResponse = %v, %q, %v", alerts, lastUpdate, ok)
+	}
+
+	restarted := &Evaluator{config: config, state: state, location: time.UTC, statePath: e.statePath, firstPoll: true}
+	if events := restarted.process(FetchResult{Alerts: alerts, LastUpdate: lastUpdate}); len(events) != 0 {
+		t.Errorf("restored cache replayed events: %+v", events)
+	}
+	if !state.ActiveAlertTypes["AIR"] {
+		t.Errorf("AIR not active after restoring cache: %v", state.ActiveAlertTypes)
+	}
+
+	// Режим -once з кешованою відповіддю бачить активну тривогу
+	settings := &atomic.Pointer[Settings]{}
+	settings.Store(&Settings{Config: config, Location: time.UTC})
+	if code := runOnce(settings, state, FetchResult{Alerts: alerts, LastUpdate: lastUpdate}, e.statePath); code != onceExitActive {
+		t.Errorf("runOnce with cached result = %d, want %d", code, onceExitActive)
+	}
+
+	// Застарілий кеш не використовується
+	state.Cache.FetchedAt = time.Now().Add(-2 * time.Hour)
+	if _, _, ok := cachedResponse(state, config); ok {
+		t.Error("expired cache was restored")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	repeated := started.Add(15 * time.Minute)
+
+	state := &State{}
+	migrateState(state)
+	state.ActiveAlertTypes["AIR"] = true
+	state.LastPlayed["AIR"] = repeated
+	state.ActiveSince["AIR"] = started
+	saveState(state, path)
+
+	loaded, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if !loaded.LastPlayed["AIR"].Equal(repeated) {
+		t.Errorf("last_played = %v, want %v", loaded.LastPlayed["AIR"], repeated)
+	}
+	if !loaded.ActiveSince["AIR"].Equal(started) {
+		t.Errorf("active_since = %v, want %v", loaded.ActiveSince["AIR"], started)
+	}
+	if !loaded.ActiveAlertTypes["AIR"] {
+		t.Errorf("active_alert_types = %v", loaded.ActiveAlertTypes)
+	}
+
+	// Порожня карта last_played зберігається як null і після читання знову стає порожньою картою
+	delete(loaded.LastPlayed, "AIR")
+	saveState(loaded, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"last_played":null`) {
+		t.Errorf("empty last_played not saved as null: %s", data)
+	}
+	reloaded, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if reloaded.LastPlayed == nil {
+		t.Fatal("last_played is nil after loading null")
+	}
+	reloaded.LastPlayed["AIR"] = repeated // Не панікує на nil карті
+}
+
+func TestLoadLegacyState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{"active_alert_types": {"AIR": true}, "last_update": "2024-05-01T10:00:00Z", "last_played": null}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadState(path)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if state.Version != stateVersion {
+		t.Errorf("version = %d, want %d", state.Version, stateVersion)
+	}
+	if state.LastPlayed == nil || state.ActiveSince == nil {
+		t.Fatalf("maps not initialised: last_played %v, active_since %v", state.LastPlayed, state.ActiveSince)
+	}
+	if !state.ActiveAlertTypes["AIR"] {
+		t.Errorf("active_alert_types = %v", state.ActiveAlertTypes)
+	}
+}
+
+func TestRepeatTimingSurvivesRestart(t *testing.T) {
+	config := &Config{EnableRepeatAudio: true, RepeatIntervalMin: 10, RepeatAudioFile: "repeat.mp3"}
+	now := time.Now().UTC()
+	started := now.Add(-40 * time.Minute)
+	alerts := []Alert{{Type: "AIR", LastUpdate: started.Format(time.RFC3339)}}
+
+	for _, tt := range []struct {
+		name       string
+		lastPlayed time.Duration
+		wantRepeat bool
+	}{
+		{"interval not elapsed", 5 * time.Minute, false},
+		{"interval elapsed", 11 * time.Minute, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			before := &State{}
+			migrateState(before)
+			before.ActiveAlertTypes["AIR"] = true
+			before.ActiveSince["AIR"] = started
+			before.LastPlayed["AIR"] = now.Add(-tt.lastPlayed)
+			before.LastUpdate = alerts[0].LastUpdate
+			saveState(before, path)
+
+			state, err := loadState(path)
+			if err != nil {
+				t.Fatalf("loadState: %v", err)
+			}
+			e := &Evaluator{config: config, state: state, location: time.UTC, statePath: path, firstPoll: true}
+			events := e.process(FetchResult{Alerts: alerts, LastUpdate: alerts[0].LastUpdate})
+			gotRepeat := len(events) == 1 && events[0].Kind == "repeat"
+			if gotRepeat != tt.wantRepeat || (!tt.wantRepeat && len(events) != 0) {
+				t.Errorf("events = %+v, want repeat %v", events, tt.wantRepeat)
+			}
+		})
+	}
+}
+
+// Повертає види подій у порядку появи
+func eventKinds(events []AlertEvent) []string {
+	kinds := make([]string, len(events))
+	for i, event := range events {
+		kinds[i] = event.Kind + ":" + event.AlertType
+	}
+	return kinds
+}
+
+func TestEvaluatorStartAndEnd(t *testing.T) {
+	e := newTestEvaluator(t, &Config{})
+	started := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	ended := time.Now().UTC().Format(time.RFC3339)
+
+	events := e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started})
+	if got := eventKinds(events); len(got) != 1 || got[0] != "start:AIR" {
+		t.Fatalf("first poll events = %v, want [start:AIR]", got)
+	}
+	if events[0].Time != started || len(events[0].Active) != 1 {
+		t.Errorf("start event = %+v", events[0])
+	}
+
+	// Та сама відповідь не створює нових подій
+	if events := e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}); len(events) != 0 {
+		t.Errorf("unchanged poll events = %v", eventKinds(events))
+	}
+
+	events = e.process(FetchResult{LastUpdate: ended})
+	if got := eventKinds(events); len(got) != 1 || got[0] != "end:AIR" {
+		t.Fatalf("end poll events = %v, want [end:AIR]", got)
+	}
+	if !events[0].AllClear || events[0].Duration <= 0 {
+		t.Errorf("end event = %+v", events[0])
+	}
+	if len(e.state.ActiveAlertTypes) != 0 || len(e.state.LastPlayed) != 0 {
+		t.Errorf("state after end: active %v, last played %v", e.state.ActiveAlertTypes, e.state.LastPlayed)
+	}
+}
+
+func TestEvaluatorFetchError(t *testing.T) {
+	e := newTestEvaluator(t, &Config{})
+	started := time.Now().UTC().Format(time.RFC3339)
+	e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started})
+
+	// Помилка запиту не означає закінчення тривоги
+	if events := e.process(FetchResult{Err: os.ErrDeadlineExceeded}); len(events) != 0 {
+		t.Errorf("fetch error events = %v", eventKinds(events))
+	}
+	if !e.state.ActiveAlertTypes["AIR"] {
+		t.Error("AIR ended after a fetch error")
+	}
+}
+
+func TestEvaluatorRepeat(t *testing.T) {
+	config := &Config{EnableRepeatAudio: true, RepeatIntervalMin: 10, RepeatAudioFile: "repeat.mp3"}
+	e := newTestEvaluator(t, config)
+	started := time.Now().UTC().Add(-5 * time.Minute).Format(time.RFC3339)
+	result := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+
+	if got := eventKinds(e.process(result)); len(got) != 1 || got[0] != "start:AIR" {
+		t.Fatalf("first poll events = %v, want [start:AIR]", got)
+	}
+	if events := e.process(result); len(events) != 0 {
+		t.Errorf("repeat before interval: %v", eventKinds(events))
+	}
+
+	// Інтервал минув від початку тривоги
+	e.state.LastPlayed["AIR"] = time.Now().UTC().Add(-11 * time.Minute)
+	events := e.process(result)
+	if got := eventKinds(events); len(got) != 1 || got[0] != "repeat:AIR" {
+		t.Fatalf("events after interval = %v, want [repeat:AIR]", got)
+	}
+	if events[0].Audio != "repeat.mp3" {
+		t.Errorf("repeat audio = %q", events[0].Audio)
+	}
+	if events := e.process(result); len(events) != 0 {
+		t.Errorf("repeat right after repeat: %v", eventKinds(events))
+	}
+}
+
+// Сигнал що тривога триває має власний відлік і не залежить від повторів
+func TestStillActiveChimeSchedule(t *testing.T) {
+	config := &Config{EnableRepeatAudio: true, RepeatIntervalMin: 10, RepeatAudioFile: "repeat.mp3", StillActiveChime: "chime.mp3", StillActiveMin: 15}
+	e := newTestEvaluator(t, config)
+	started := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	result := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+	e.process(result)
+
+	// Повтор не зсуває відлік сигналу
+	e.state.ActiveSince["AIR"] = time.Now().UTC().Add(-12 * time.Minute)
+	e.state.LastPlayed["AIR"] = time.Now().UTC().Add(-11 * time.Minute)
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"repeat:AIR"}) {
+		t.Fatalf("events at 12 min = %v, want [repeat:AIR]", got)
+	}
+	e.state.ActiveSince["AIR"] = time.Now().UTC().Add(-16 * time.Minute)
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"chime:AIR"}) {
+		t.Fatalf("events at 16 min = %v, want [chime:AIR]", got)
+	}
+
+	// Наступний сигнал рахується від попереднього, а не від останнього повтору
+	e.state.LastPlayed["AIR"] = time.Now().UTC().Add(-11 * time.Minute)
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"repeat:AIR"}) {
+		t.Errorf("events after chime = %v, want [repeat:AIR]", got)
+	}
+	e.state.LastChime["AIR"] = time.Now().UTC().Add(-16 * time.Minute)
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"chime:AIR"}) {
+		t.Errorf("events after chime interval = %v, want [chime:AIR]", got)
+	}
+
+	// Сигнал лунає і без повторних звуків
+	config.EnableRepeatAudio = false
+	e.state.LastChime["AIR"] = time.Now().UTC().Add(-16 * time.Minute)
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"chime:AIR"}) {
+		t.Errorf("events without repeats = %v, want [chime:AIR]", got)
+	}
+}
+
+func TestEvaluatorOffCooldown(t *testing.T) {
+	e := newTestEvaluator(t, &Config{AlertOffCooldown: 60})
+	started := time.Now().UTC().Format(time.RFC3339)
+	active := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+	empty := FetchResult{LastUpdate: started}
+
+	e.process(active)
+
+	// Подія зникла ненадовго - відбій відкладено, а після повернення скасовано
+	if events := e.process(empty); len(events) != 0 {
+		t.Errorf("events during cooldown = %v", eventKinds(events))
+	}
+	if events := e.process(active); len(events) != 0 {
+		t.Errorf("events after flap = %v", eventKinds(events))
+	}
+	if _, pending := e.state.PendingOff["AIR"]; pending {
+		t.Error("pending end not cancelled after the alert returned")
+	}
+
+	// Подія відсутня довше за alert_off_cooldown_sec
+	e.process(empty)
+	e.state.PendingOff["AIR"] = time.Now().Add(-2 * time.Minute)
+	events := e.process(empty)
+	if got := eventKinds(events); len(got) != 1 || got[0] != "end:AIR" {
+		t.Fatalf("events after cooldown = %v, want [end:AIR]", got)
+	}
+}
+
+func TestEvaluatorAllClearOncePerPoll(t *testing.T) {
+	e := newTestEvaluator(t, &Config{AllClearTypes: []string{"AIR", "ARTILLERY"}, ImmediateTypes: []string{"ARTILLERY"}})
+	started := time.Now().UTC().Format(time.RFC3339)
+	e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}, {Type: "ARTILLERY", LastUpdate: started}}, LastUpdate: started})
+	if len(e.state.ActiveAlertTypes) != 2 {
+		t.Fatalf("active = %v, want AIR and ARTILLERY", e.state.ActiveAlertTypes)
+	}
+
+	allClear := 0
+	for _, event := range e.process(FetchResult{LastUpdate: started}) {
+		if event.AllClear {
+			allClear++
+		}
+	}
+	if allClear != 1 {
+		t.Errorf("all-clear marked on %d end events, want 1", allClear)
+	}
+}
+
+func TestEvaluatorDeescalation(t *testing.T) {
+	e := newTestEvaluator(t, &Config{EnableDeescalation: true, ImmediateTypes: []string{"ARTILLERY", "CHEMICAL"}})
+	started := time.Now().UTC().Format(time.RFC3339)
+	result := func(types ...string) FetchResult {
+		var alerts []Alert
+		for _, alertType := range types {
+			alerts = append(alerts, Alert{Type: alertType, LastUpdate: started})
+		}
+		return FetchResult{Alerts: alerts, LastUpdate: started}
+	}
+
+	// Кількість подій зростає - сигналу покращення немає
+	e.process(result("AIR"))
+	if got := eventKinds(e.process(result("AIR", "ARTILLERY", "CHEMICAL"))); !slices.Equal(got, []string{"start:ARTILLERY", "start:CHEMICAL"}) {
+		t.Fatalf("increase events = %v, want ARTILLERY and CHEMICAL starts", got)
+	}
+
+	// Кількість зменшилась - сигнал покращення замість відбою
+	for _, step := range []struct {
+		types     []string
+		ended     string
+		remaining int
+	}{
+		{[]string{"AIR", "CHEMICAL"}, "ARTILLERY", 2},
+		{[]string{"AIR"}, "CHEMICAL", 1},
+	} {
+		events := e.process(result(step.types...))
+		if got := eventKinds(events); !slices.Equal(got, []string{"end:" + step.ended, "deescalation:"}) {
+			t.Fatalf("decrease events = %v, want end:%s and deescalation", got, step.ended)
+		}
+		for _, event := range events {
+			if event.Remaining != step.remaining {
+				t.Errorf("%s event Remaining = %d, want %d", event.Kind, event.Remaining, step.remaining)
+			}
+			if event.AllClear {
+				t.Errorf("%s event marked all-clear while %d types remain", event.Kind, step.remaining)
+			}
+		}
+	}
+
+	// Подій не залишилось - звичайний відбій
+	events := e.process(result())
+	if got := eventKinds(events); !slices.Equal(got, []string{"end:AIR"}) {
+		t.Fatalf("clear events = %v, want [end:AIR]", got)
+	}
+	if !events[0].AllClear || events[0].Remaining != 0 {
+		t.Errorf("clear event = %+v, want all-clear with nothing remaining", events[0])
+	}
+}
+
+// Термінова подія оминає вибір пріоритетної події та ui_smoothing_sec
+func TestImmediateTypeBypassesStaging(t *testing.T) {
+	started := time.Now().UTC().Format(time.RFC3339)
+	air := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+	both := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}, {Type: "BALLISTIC", LastUpdate: started}}, LastUpdate: started}
+
+	// Звичайний тип не починається, поки активна пріоритетна подія
+	e := newTestEvaluator(t, &Config{UISmoothingSec: 60})
+	e.process(air)
+	if events := e.process(both); len(events) != 0 {
+		t.Errorf("regular type events = %v, want none while AIR is active", eventKinds(events))
+	}
+
+	e = newTestEvaluator(t, &Config{UISmoothingSec: 60, ImmediateTypes: []string{"BALLISTIC"}})
+	events := e.process(air)
+	if got := eventKinds(events); len(got) != 1 || got[0] != "start:AIR" || events[0].Immediate {
+		t.Fatalf("first poll events = %+v, want a regular start:AIR", events)
+	}
+	events = e.process(both)
+	if got := eventKinds(events); len(got) != 1 || got[0] != "start:BALLISTIC" {
+		t.Fatalf("immediate poll events = %v, want [start:BALLISTIC]", got)
+	}
+	if !events[0].Immediate {
+		t.Errorf("start event = %+v, want Immediate", events[0])
+	}
+	if !e.state.ActiveAlertTypes["AIR"] || !e.state.ActiveAlertTypes["BALLISTIC"] {
+		t.Errorf("active = %v, want AIR and BALLISTIC", e.state.ActiveAlertTypes)
+	}
+	// Згладжування відображення не затримує термінову подію
+	if got := presentedAlertTypes(e.state, e.config, time.Now()); !slices.Equal(got, []string{"BALLISTIC"}) {
+		t.Errorf("presented = %v, want [BALLISTIC]", got)
+	}
+}
+
+// Запускати з go test -race: saveState не змінює стан, тож читачі Snapshot не конфліктують із записом
+func TestSaveStateConcurrentWithSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := &State{}
+	migrateState(state)
+
+	done := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			snapshot := state.Snapshot()
+			_ = len(snapshot.LastPlayed)
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		state.mu.Lock()
+		// Порожня карта last_played зберігається як null, решта - як є
+		if i%2 == 0 {
+			state.LastPlayed["AIR"] = time.Now().UTC()
+			state.ActiveAlertTypes["AIR"] = true
+		} else {
+			delete(state.LastPlayed, "AIR")
+			delete(state.ActiveAlertTypes, "AIR")
+		}
+		saveState(state, path)
+		state.mu.Unlock()
+	}
+	close(done)
+	<-read
+}
+
+func TestRemoveComments(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"url with //", `{"api_url": "https://example.com/api"}`, `{"api_url": "https://example.com/api"}`, false},
+		{"trailing //", "{\"debug\": true, // нотатка\n\"x\": 1}", "{\"debug\": true, \n\"x\": 1}", false},
+		{"full line #", "# коментар\n{}", "\n{}", false},
+		{"# inside string", `{"name": "кімната #1"}`, `{"name": "кімната #1"}`, false},
+		{"escaped quote before //", `{"a": "x\" // y", "b": 1} // z`, `{"a": "x\" // y", "b": 1} `, false},
+		{"block", "{/* блок\nкоментар */\"a\": 1}", "{\n\"a\": 1}", false},
+		{"unterminated block", "{\n\"a\": 1 /* без кінця\n}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := removeComments([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "рядку 2") {
+					t.Errorf("error %q does not name line 2", err)
+				}
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("removeComments(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectPrimaryAlert(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority []string
+		alerts   []Alert
+		want     string
+	}{
+		{"no alerts", nil, nil, ""},
+		{"default prefers AIR", nil, []Alert{
+			{Type: "ARTILLERY", LastUpdate: "2024-05-01T09:00:00Z"},
+			{Type: "AIR", LastUpdate: "2024-05-01T10:00:00Z"},
+		}, "AIR"},
+		{"priority list order", []string{"CHEMICAL", "AIR"}, []Alert{
+			{Type: "AIR", LastUpdate: "2024-05-01T09:00:00Z"},
+			{Type: "CHEMICAL", LastUpdate: "2024-05-01T10:00:00Z"},
+		}, "CHEMICAL"},
+		{"listed beats unlisted", []string{"AIR"}, []Alert{
+			{Type: "ARTILLERY", LastUpdate: "2024-05-01T08:00:00Z"},
+			{Type: "AIR", LastUpdate: "2024-05-01T10:00:00Z"},
+		}, "AIR"},
+		{"unlisted by earliest lastUpdate", []string{"AIR"}, []Alert{
+			{Type: "ARTILLERY", LastUpdate: "2024-05-01T10:00:00Z"},
+			{Type: "URBAN_FIGHTS", LastUpdate: "2024-05-01T09:00:00Z"},
+			{Type: "CHEMICAL", LastUpdate: "2024-05-01T09:30:00Z"},
+		}, "URBAN_FIGHTS"},
+		{"same type by earliest lastUpdate", []string{"AIR"}, []Alert{
+			{Type: "AIR", LastUpdate: "2024-05-01T10:00:00Z", Region: "Київ"},
+			{Type: "AIR", LastUpdate: "2024-05-01T09:00:00Z", Region: "Львів"},
+		}, "AIR@Львів"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := selectPrimaryAlert(tt.alerts, &Config{AlertPriority: tt.priority})
+			got := ""
+			if selected != nil {
+				got = selected.Type
+				if strings.Contains(tt.want, "@") {
+					got += "@" + selected.Region
+				}
+			}
+			if got != tt.want {
+				t.Errorf("selectPrimaryAlert = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectByServerLevel(t *testing.T) {
+	level := func(n int) *int { return &n }
+	tests := []struct {
+		name   string
+		alerts []Alert
+		want   string
+	}{
+		{"no alerts", nil, ""},
+		{"no levels", []Alert{{Type: "AIR"}, {Type: "ARTILLERY"}}, ""},
+		{"highest level", []Alert{
+			{Type: "AIR", Level: level(1)},
+			{Type: "ARTILLERY", Level: level(3)},
+			{Type: "CHEMICAL", Level: level(2)},
+		}, "ARTILLERY"},
+		{"alerts without level are skipped", []Alert{
+			{Type: "AIR"},
+			{Type: "ARTILLERY", Level: level(0)},
+		}, "ARTILLERY"},
+		{"equal levels by earliest lastUpdate", []Alert{
+			{Type: "AIR", LastUpdate: "2024-05-01T10:00:00Z", Level: level(2)},
+			{Type: "ARTILLERY", LastUpdate: "2024-05-01T09:00:00Z", Level: level(2)},
+		}, "ARTILLERY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if selected := selectByServerLevel(tt.alerts); selected != nil {
+				got = selected.Type
+			}
+			if got != tt.want {
+				t.Errorf("selectByServerLevel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Без рівнів у відповіді подія вибирається за alert_priority
+	started := time.Now().UTC().Format(time.RFC3339)
+	for _, tt := range []struct {
+		name   string
+		alerts []Alert
+		want   string
+	}{
+		{"server level", []Alert{{Type: "AIR", LastUpdate: started, Level: level(1)}, {Type: "ARTILLERY", LastUpdate: started, Level: level(2)}}, "start:ARTILLERY"},
+		{"fallback to priority", []Alert{{Type: "ARTILLERY", LastUpdate: started}, {Type: "AIR", LastUpdate: started}}, "start:AIR"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEvaluator(t, &Config{UseServerPriority: true})
+			if got := eventKinds(e.process(FetchResult{Alerts: tt.alerts, LastUpdate: started})); !slices.Equal(got, []string{tt.want}) {
+				t.Errorf("events = %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatAlertTypeUsesPriority(t *testing.T) {
+	state := &State{}
+	migrateState(state)
+	now := time.Now().UTC()
+	state.ActiveAlertTypes["ARTILLERY"] = true
+	state.ActiveSince["ARTILLERY"] = now.Add(-time.Hour)
+	state.ActiveAlertTypes["AIR"] = true
+	state.ActiveSince["AIR"] = now.Add(-time.Minute)
+
+	if got := repeatAlertType(state, &Config{}); got != "AIR" {
+		t.Errorf("default priority: repeatAlertType = %q, want AIR", got)
+	}
+	if got := repeatAlertType(state, &Config{AlertPriority: []string{"CHEMICAL"}}); got != "ARTILLERY" {
+		t.Errorf("unlisted types: repeatAlertType = %q, want the earliest ARTILLERY", got)
+	}
+}
+
+func TestInitStartupLastPlayed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	serverStart := now.Add(-25 * time.Minute)
+	interval := 10 * time.Minute
+	tests := []struct {
+		policy     string
+		serverTime string
+		firstAt    time.Time // Час першого повтору
+	}{
+		{"", serverStart.Format(time.RFC3339), now.Add(interval)},
+		{"now", serverStart.Format(time.RFC3339), now.Add(interval)},
+		{"epoch", serverStart.Format(time.RFC3339), now},
+		{"from_server", serverStart.Format(time.RFC3339), now},
+		{"from_server", now.Add(-5 * time.Minute).Format(time.RFC3339), now.Add(5 * time.Minute)},
+		{"from_server", "не час", now.Add(interval)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy+"/"+tt.serverTime, func(t *testing.T) {
+			state := &State{}
+			migrateState(state)
+			initStartupLastPlayed(state, &Config{StartupLastPlayed: tt.policy}, "AIR", tt.serverTime, now)
+
+			// Повтор лунає, щойно з останнього відтворення мине інтервал, але не раніше запуску
+			firstAt := state.LastPlayed["AIR"].Add(interval)
+			if firstAt.Before(now) {
+				firstAt = now
+			}
+			if !firstAt.Equal(tt.firstAt) {
+				t.Errorf("first repeat at %v, want %v", firstAt, tt.firstAt)
+			}
+		})
+	}
+}
+
+func TestStartupLastPlayedOnFirstPoll(t *testing.T) {
+	started := time.Now().UTC().Add(-25 * time.Minute)
+	result := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started.Format(time.RFC3339)}}, LastUpdate: started.Format(time.RFC3339)}
+	for _, tt := range []struct {
+		policy     string
+		wantRepeat bool
+	}{
+		{"", false},    // За замовчуванням як now
+		{"now", false}, // Відлік від запуску
+		{"epoch", true},
+		{"from_server", true},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			e := newTestEvaluator(t, &Config{EnableRepeatAudio: true, RepeatIntervalMin: 10, RepeatAudioFile: "repeat.mp3", StartupLastPlayed: tt.policy})
+			want := []string{"start:AIR"}
+			if tt.wantRepeat {
+				want = append(want, "repeat:AIR")
+			}
+			if got := eventKinds(e.process(result)); !slices.Equal(got, want) {
+				t.Errorf("first poll events = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// Тривога з давнім lastUpdate, що зʼявилась під час роботи: тривалість від початку за сервером,
+// а перший повтор лише через повний інтервал після звуку початку
+func TestStartResetsRepeatCountdown(t *testing.T) {
+	e := newTestEvaluator(t, &Config{EnableRepeatAudio: true, RepeatIntervalMin: 10, RepeatAudioFile: "repeat.mp3"})
+	started := time.Now().UTC().Add(-25 * time.Minute).Truncate(time.Second)
+	e.process(FetchResult{LastUpdate: started.Format(time.RFC3339)})
+
+	result := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started.Format(time.RFC3339)}}, LastUpdate: started.Format(time.RFC3339)}
+	if got := eventKinds(e.process(result)); !slices.Equal(got, []string{"start:AIR"}) {
+		t.Fatalf("start poll events = %v, want [start:AIR]", got)
+	}
+	if !e.state.ActiveSince["AIR"].Equal(started) {
+		t.Errorf("active since = %s, want the server start %s", e.state.ActiveSince["AIR"], started)
+	}
+	if events := e.process(result); len(events) != 0 {
+		t.Errorf("events right after start = %v, want none", eventKinds(events))
+	}
+}
+
+func TestRestartDedup(t *testing.T) {
+	config := &Config{RestartDedupMin: 10, EnableRepeatAudio: true, RepeatIntervalMin: 1, RepeatAudioFile: "repeat.mp3"}
+	started := time.Now().UTC().Add(-30 * time.Minute).Format(time.RFC3339)
+	unchanged := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+
+	e := newTestEvaluator(t, config)
+	if got := eventKinds(e.process(unchanged)); !slices.Contains(got, "start:AIR") {
+		t.Fatalf("first run events = %v, want start:AIR", got)
+	}
+	// Повторний сигнал вже мав би лунати
+	e.state.LastPlayed["AIR"] = time.Now().UTC().Add(-5 * time.Minute)
+	saveState(e.state, e.statePath)
+
+	restart := func() *Evaluator {
+		t.Helper()
+		state, err := loadState(e.statePath)
+		if err != nil {
+			t.Fatalf("loadState: %v", err)
+		}
+		return &Evaluator{config: config, state: state, location: time.UTC, statePath: e.statePath, firstPoll: true}
+	}
+
+	// Перезапуск з тією самою відповіддю: звуки не відтворюються
+	if events := restart().process(unchanged); len(events) != 0 {
+		t.Errorf("restart with unchanged response events = %v, want none", eventKinds(events))
+	}
+
+	// Перезапуск зі зміненою відповіддю обробляється як звичайно
+	changedAt := time.Now().UTC().Format(time.RFC3339)
+	changed := FetchResult{Alerts: []Alert{{Type: "ARTILLERY", LastUpdate: changedAt}}, LastUpdate: changedAt}
+	if got := eventKinds(restart().process(changed)); !slices.Equal(got, []string{"start:ARTILLERY", "end:AIR"}) {
+		t.Errorf("restart with changed response events = %v, want [start:ARTILLERY end:AIR]", got)
+	}
+
+	// Після restart_dedup_min та сама відповідь знову обробляється
+	state, err := loadState(e.statePath)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	state.ProcessedAt = time.Now().UTC().Add(-11 * time.Minute)
+	state.LastPlayed["ARTILLERY"] = time.Now().UTC().Add(-5 * time.Minute)
+	expired := &Evaluator{config: config, state: state, location: time.UTC, statePath: e.statePath, firstPoll: true}
+	if got := eventKinds(expired.process(changed)); !slices.Equal(got, []string{"repeat:ARTILLERY"}) {
+		t.Errorf("restart after the dedup window events = %v, want [repeat:ARTILLERY]", got)
+	}
+}
+
+func TestTypeGroups(t *testing.T) {
+	config := &Config{TypeGroups: map[string][]string{
+		"STRIKE":    {"MISSILE", "SHELLING"},
+		"ARTILLERY": {"ARTILLERY", "SHELLING"},
+	}}
+
+	// Тип з кількох груп завжди належить до першої за абеткою
+	for i := 0; i < 20; i++ {
+		grouped := applyTypeGroups([]Alert{{Type: "SHELLING"}, {Type: "MISSILE"}, {Type: "AIR"}}, config)
+		got := []string{grouped[0].Type, grouped[1].Type, grouped[2].Type}
+		if want := []string{"ARTILLERY", "STRIKE", "AIR"}; !slices.Equal(got, want) {
+			t.Fatalf("applyTypeGroups = %v, want %v", got, want)
+		}
+	}
+
+	// Група вмикається будь-яким своїм типом, зміна типу в межах групи не є новою тривогою
+	e := newTestEvaluator(t, config)
+	started := time.Now().UTC().Add(-2 * time.Minute).Format(time.RFC3339)
+	if got := eventKinds(e.process(FetchResult{Alerts: []Alert{{Type: "SHELLING", LastUpdate: started}}, LastUpdate: started})); !slices.Equal(got, []string{"start:ARTILLERY"}) {
+		t.Errorf("group start events = %v, want [start:ARTILLERY]", got)
+	}
+	switched := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	if got := eventKinds(e.process(FetchResult{Alerts: []Alert{{Type: "ARTILLERY", LastUpdate: switched}}, LastUpdate: switched})); len(got) != 0 {
+		t.Errorf("type change within the group events = %v, want none", got)
+	}
+	ended := time.Now().UTC().Format(time.RFC3339)
+	if got := eventKinds(e.process(FetchResult{LastUpdate: ended})); !slices.Equal(got, []string{"end:ARTILLERY"}) {
+		t.Errorf("group clear events = %v, want [end:ARTILLERY]", got)
+	}
+}
+
+func TestMaxAlertAge(t *testing.T) {
+	config := &Config{MaxAlertAgeMin: 60, EnableRepeatAudio: true, RepeatIntervalMin: 1, RepeatAudioFile: "repeat.mp3"}
+	now := time.Now().UTC()
+	fresh := Alert{Type: "AIR", LastUpdate: now.Add(-20 * time.Minute).Format(time.RFC3339)}
+	stale := Alert{Type: "ARTILLERY", LastUpdate: now.Add(-5 * time.Hour).Format(time.RFC3339)}
+
+	e := newTestEvaluator(t, config)
+	if got := eventKinds(e.process(FetchResult{Alerts: []Alert{fresh, stale}, LastUpdate: fresh.LastUpdate})); !slices.Contains(got, "start:AIR") {
+		t.Fatalf("first poll events = %v, want start:AIR", got)
+	}
+	if !e.state.Expired["ARTILLERY"] || e.state.Expired["AIR"] {
+		t.Errorf("expired = %v, want only ARTILLERY", e.state.Expired)
+	}
+
+	// Для свіжої події повтори лунають як завжди
+	e.state.LastPlayed["AIR"] = now.Add(-5 * time.Minute)
+	if got := eventKinds(e.process(FetchResult{Alerts: []Alert{fresh, stale}, LastUpdate: fresh.LastUpdate})); !slices.Equal(got, []string{"repeat:AIR"}) {
+		t.Errorf("fresh alert events = %v, want [repeat:AIR]", got)
+	}
+
+	// Подія застаріла, але сервер досі її повертає: ні повтору, ні відбою
+	staleAir := Alert{Type: "AIR", LastUpdate: now.Add(-2 * time.Hour).Format(time.RFC3339)}
+	e.state.LastPlayed["AIR"] = now.Add(-5 * time.Minute)
+	if got := eventKinds(e.process(FetchResult{Alerts: []Alert{staleAir, stale}, LastUpdate: fresh.LastUpdate})); len(got) != 0 {
+		t.Errorf("stale alert events = %v, want none", got)
+	}
+	if !e.state.ActiveAlertTypes["AIR"] {
+		t.Errorf("stale AIR is no longer active: %v", e.state.ActiveAlertTypes)
+	}
+
+	// Відбій лише тоді, коли сервер більше не повертає подію
+	events := e.process(FetchResult{LastUpdate: now.Format(time.RFC3339)})
+	if got := eventKinds(events); !slices.Equal(got, []string{"end:AIR"}) || !events[0].AllClear {
+		t.Errorf("clear poll events = %+v, want all-clear end:AIR", events)
+	}
+}
+
+func TestRegionNameFilter(t *testing.T) {
+	regions := []Region{
+		{RegionName: "Київська область", RegionEngName: "Kyiv Oblast", LastUpdate: "2024-05-01T10:00:00Z", ActiveAlerts: []Alert{{Type: "AIR"}}},
+		{RegionName: "м. Київ", RegionEngName: "Kyiv City", LastUpdate: "2024-05-01T10:05:00Z", ActiveAlerts: []Alert{{Type: "ARTILLERY"}}},
+		{RegionName: "Харківська область", RegionEngName: "Kharkiv Oblast", LastUpdate: "2024-05-01T10:10:00Z", ActiveAlerts: []Alert{{Type: "CHEMICAL"}}},
+	}
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"Київ", []string{"AIR", "ARTILLERY"}},
+		{"^Kyiv City$", []string{"ARTILLERY"}},
+		{"(?i)kharkiv", []string{"CHEMICAL"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			config := &Config{APIURL: "https://example.com", TimeZone: "UTC", RegionNameFilter: tt.filter}
+			if err := validateConfig(config); err != nil {
+				t.Fatalf("validateConfig: %v", err)
+			}
+			alerts, _, err := alertsFromRegions(regions, config)
+			if err != nil {
+				t.Fatalf("alertsFromRegions: %v", err)
+			}
+			var types []string
+			for _, alert := range alerts {
+				types = append(types, alert.Type)
+			}
+			if !slices.Equal(types, tt.want) {
+				t.Errorf("types = %v, want %v", types, tt.want)
+			}
+		})
+	}
+
+	// Жоден регіон не відповідає фільтру
+	config := &Config{APIURL: "https://example.com", TimeZone: "UTC", RegionNameFilter: "Одес"}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig: %v", err)
+	}
+	if _, _, err := alertsFromRegions(regions, config); err == nil {
+		t.Error("no error when no region matches the filter")
+	}
+
+	// Некоректний вираз - помилка конфігурації, а не пошук підрядка
+	config = &Config{APIURL: "https://example.com", TimeZone: "UTC", RegionNameFilter: "Київ("}
+	if err := validateConfig(config); err == nil || !strings.Contains(err.Error(), "region_name_filter") {
+		t.Errorf("validateConfig error = %v, want an invalid region_name_filter", err)
+	}
+}
+
+// Файл зʼявляється лише після першої невдалої спроби
+func TestDecodeWithRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "air.wav")
+	calls := 0
+	decode := func(path string) (beep.StreamSeekCloser, beep.Format, error) {
+		calls++
+		streamer, format, err := decodeAudio(path)
+		if calls == 1 {
+			f, createErr := os.Create(path)
+			if createErr != nil {
+				t.Fatal(createErr)
+			}
+			defer f.Close()
+			if err := wav.Encode(f, beep.Silence(800), beep.Format{SampleRate: 8000, NumChannels: 1, Precision: 2}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return streamer, format, err
+	}
+
+	// За замовчуванням лише одна спроба
+	if _, _, err := decodeWithRetry(&Config{}, path, decode); err == nil || calls != 1 {
+		t.Fatalf("default attempts: err %v after %d calls, want an error after 1", err, calls)
+	}
+
+	os.Remove(path)
+	calls = 0
+	streamer, format, err := decodeWithRetry(&Config{AudioRetries: 3, AudioRetryDelayMs: 1}, path, decode)
+	if err != nil {
+		t.Fatalf("decodeWithRetry: %v", err)
+	}
+	defer streamer.Close()
+	if calls != 2 {
+		t.Errorf("decode called %d times, want 2", calls)
+	}
+	if format.SampleRate != 8000 || streamer.Len() != 800 {
+		t.Errorf("decoded %d samples at %d Hz, want 800 at 8000", streamer.Len(), format.SampleRate)
+	}
+}
+
+func TestMaxRuntimeRestart(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRuntime   time.Duration
+		elapsed      time.Duration
+		active       int
+		playing      bool
+		wantRestart  bool
+		wantDeferred bool
+	}{
+		{"disabled", 0, time.Hour, 0, false, false, false},
+		{"before max runtime", time.Hour, 59 * time.Minute, 0, false, false, false},
+		{"no active alerts", time.Hour, time.Hour, 0, false, true, false},
+		{"active alerts", time.Hour, 2 * time.Hour, 1, false, false, true},
+		{"audio playing", time.Hour, 2 * time.Hour, 0, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restart, deferred := maxRuntimeRestart(tt.maxRuntime, tt.elapsed, tt.active, tt.playing)
+			if restart != tt.wantRestart || deferred != tt.wantDeferred {
+				t.Errorf("maxRuntimeRestart = %v, %v; want %v, %v", restart, deferred, tt.wantRestart, tt.wantDeferred)
+			}
+		})
+	}
+}
+
+// Один тип тривоги у двох регіонах - одна подія з найранішим lastUpdate
+func TestDedupeAlertTypes(t *testing.T) {
+	earlier := time.Now().UTC().Add(-20 * time.Minute).Truncate(time.Second)
+	later := earlier.Add(10 * time.Minute)
+	regions := []Region{
+		{RegionName: "Київ", LastUpdate: later.Format(time.RFC3339), ActiveAlerts: []Alert{{Type: "AIR", LastUpdate: later.Format(time.RFC3339), Region: "Київ"}}},
+		{RegionName: "Бровари", LastUpdate: earlier.Format(time.RFC3339), ActiveAlerts: []Alert{{Type: "AIR", LastUpdate: earlier.Format(time.RFC3339), Region: "Бровари"}}},
+	}
+	config := &Config{Regions: []RegionRef{{Name: "Київ"}, {Name: "Бровари"}}}
+	alerts, lastUpdate, err := alertsFromRegions(regions, config)
+	if err != nil {
+		t.Fatalf("alertsFromRegions: %v", err)
+	}
+
+	unique := dedupeAlertTypes(alerts)
+	if len(unique) != 1 || unique[0].LastUpdate != earlier.Format(time.RFC3339) || unique[0].Region != "Бровари" {
+		t.Fatalf("dedupeAlertTypes = %+v, want the earliest AIR alert", unique)
+	}
+
+	e := newTestEvaluator(t, config)
+	events := e.process(FetchResult{Alerts: alerts, LastUpdate: lastUpdate})
+	if got := eventKinds(events); !slices.Equal(got, []string{"start:AIR"}) {
+		t.Fatalf("events = %v, want one start:AIR", got)
+	}
+	if events[0].Time != earlier.Format(time.RFC3339) || !e.state.ActiveSince["AIR"].Equal(earlier) {
+		t.Errorf("start event time %s, active since %s; want the earliest lastUpdate %s", events[0].Time, e.state.ActiveSince["AIR"], earlier)
+	}
+}
+
+func TestApplyPollJitter(t *testing.T) {
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		jitterSec int
+		r         float64
+		want      time.Duration
+	}{
+		{"disabled", 30 * time.Second, 0, 0, 30 * time.Second},
+		{"r 0 shortens by jitter", 30 * time.Second, 5, 0, 25 * time.Second},
+		{"r 0.5 keeps delay", 30 * time.Second, 5, 0.5, 30 * time.Second},
+		{"r 1 lengthens by jitter", 30 * time.Second, 5, 1, 35 * time.Second},
+		{"one second floor", 2 * time.Second, 5, 0, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyPollJitter(tt.delay, &Config{RequestJitterSec: tt.jitterSec}, tt.r); got != tt.want {
+				t.Errorf("applyPollJitter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnqueueAudioEvent(t *testing.T) {
+	repeat := AlertEvent{Kind: "repeat", AlertType: "AIR"}
+	for _, dedupe := range []bool{false, true} {
+		config := &Config{AudioQueueDedupe: dedupe}
+
+		// Черга обмежена в обох режимах, початок і кінець тривоги додаються завжди
+		var queue []AlertEvent
+		for i := 0; i < maxAudioQueue+2; i++ {
+			queue = enqueueAudioEvent(config, queue, AlertEvent{Kind: "chime", AlertType: string(rune('A' + i))}, nil)
+		}
+		if len(queue) != maxAudioQueue {
+			t.Errorf("dedupe %v: queue length %d, want %d", dedupe, len(queue), maxAudioQueue)
+		}
+		queue = enqueueAudioEvent(config, queue, AlertEvent{Kind: "start", AlertType: "AIR"}, nil)
+		if len(queue) != maxAudioQueue+1 {
+			t.Errorf("dedupe %v: start event not queued into a full queue", dedupe)
+		}
+
+		// Однакові сигнали пропускаються лише з audio_queue_dedupe
+		queue = enqueueAudioEvent(config, nil, repeat, &repeat)
+		queue = enqueueAudioEvent(config, queue, repeat, nil)
+		queue = enqueueAudioEvent(config, queue, repeat, nil)
+		want := 3
+		if dedupe {
+			want = 1
+		}
+		if len(queue) != want {
+			t.Errorf("dedupe %v: %d repeats queued, want %d", dedupe, len(queue), want)
+		}
+	}
+}
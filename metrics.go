@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Метрики Prometheus, доступні на /metrics, якщо вказано metrics_listen_addr
+var (
+	fetchTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "signal_fetch_total",
+		Help: "Кількість запитів до джерела за результатом (success, failure)",
+	}, []string{"result"})
+	fetchLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "signal_fetch_duration_seconds",
+		Help:    "Тривалість HTTP запиту до джерела до отримання відповіді",
+		Buckets: prometheus.DefBuckets,
+	})
+	audioPlays = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "signal_audio_plays_total",
+		Help: "Кількість відтворених звуків за типом події та видом звуку",
+	}, []string{"alert_type", "kind"})
+	activeAlerts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "signal_active_alerts",
+		Help: "Кількість активних тривог",
+	})
+	sourceUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signal_source_up",
+		Help: "1, якщо останній запит до джерела успішний",
+	}, []string{"url"})
+	sourceFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signal_source_consecutive_failures",
+		Help: "Кількість невдалих запитів до джерела поспіль",
+	}, []string{"url"})
+	sourceLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signal_source_latency_seconds",
+		Help: "Тривалість останнього запиту до джерела",
+	}, []string{"url"})
+)
+
+func init() {
+	prometheus.MustRegister(fetchTotal, fetchLatency, audioPlays, activeAlerts, sourceUp, sourceFailures, sourceLatency)
+}
+
+// Оновлює метрики окремого джерела з api_urls
+func recordSourceMetrics(health SourceHealth) {
+	up := 0.0
+	if health.ConsecutiveFailures == 0 {
+		up = 1
+	}
+	sourceUp.WithLabelValues(health.URL).Set(up)
+	sourceFailures.WithLabelValues(health.URL).Set(float64(health.ConsecutiveFailures))
+	sourceLatency.WithLabelValues(health.URL).Set(health.Latency.Seconds())
+}
+
+// Враховує результат запиту до джерела
+func recordFetchMetrics(err error) {
+	if err != nil {
+		fetchTotal.WithLabelValues("failure").Inc()
+		return
+	}
+	fetchTotal.WithLabelValues("success").Inc()
+}
+
+// Кількість останніх запитів для ковзного середнього тривалості
+const latencyWindow = 20
+
+// latencyTracker рахує ковзне середнє тривалості запитів до джерела
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+var requestLatency = &latencyTracker{}
+
+// Додає тривалість запиту і повертає середнє за останні latencyWindow запитів
+func (t *latencyTracker) add(latency time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < latencyWindow {
+		t.samples = append(t.samples, latency)
+	} else {
+		t.samples[t.next] = latency
+		t.next = (t.next + 1) % latencyWindow
+	}
+	var total time.Duration
+	for _, sample := range t.samples {
+		total += sample
+	}
+	return total / time.Duration(len(t.samples))
+}
+
+// Враховує тривалість запиту до джерела: гістограма Prometheus, ковзне середнє,
+// запис у лог з debug та попередження, якщо запит довший за slow_request_ms
+func (t *latencyTracker) record(config *Config, url string, latency time.Duration) {
+	fetchLatency.Observe(latency.Seconds())
+	average := t.add(latency)
+	if config.Debug {
+		logDebug("Запит до %s тривав %s, середнє: %s", url, latency.Round(time.Millisecond), average.Round(time.Millisecond))
+	}
+	if config.SlowRequestMs > 0 && latency > time.Duration(config.SlowRequestMs)*time.Millisecond {
+		logEvent(slog.LevelWarn, fmt.Sprintf("Попередження: повільний запит до %s: %s (поріг %d мс, середнє %s)", url, latency.Round(time.Millisecond), config.SlowRequestMs, average.Round(time.Millisecond)),
+			"event", "slow_request", "url", url, "duration_ms", latency.Milliseconds(), "average_ms", average.Milliseconds())
+	}
+}
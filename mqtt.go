@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Час очікування підтвердження публікації від брокера MQTT
+const mqttTimeout = 10 * time.Second
+
+// mqttPublisher публікує стан тривог у брокер MQTT. Повідомлення зберігаються брокером
+// (retained), тож нові підписники одразу отримують поточний стан
+type mqttPublisher struct {
+	client    mqtt.Client
+	topic     string
+	discovery *haDiscovery // nil, якщо mqtt_ha_discovery вимкнено
+}
+
+// Підключається до брокера mqtt_broker, якщо він вказаний. Підключення відбувається у фоні:
+// недоступний брокер не затримує запуск, а після втрати звʼязку клієнт підключається знову
+func startMQTT(config *Config, state *State) *mqttPublisher {
+	if config.MQTTBroker == "" {
+		return nil
+	}
+	topic := config.MQTTTopic
+	if topic == "" {
+		topic = "signal" // Значення за замовчуванням
+	}
+	clientID := "signal-app"
+	if config.InstanceName != "" {
+		clientID += "-" + config.InstanceName
+	}
+	p := &mqttPublisher{topic: topic}
+	if config.MQTTHADiscovery {
+		p.discovery = newHADiscovery(config, clientID)
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.MQTTBroker).
+		SetClientID(clientID).
+		SetUsername(config.MQTTUsername).
+		SetPassword(config.MQTTPassword).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(30*time.Second).
+		SetWill(topic+"/status", "offline", 1, true)
+	// Після кожного (пере)підключення публікуємо актуальний стан
+	opts.SetOnConnectHandler(func(mqtt.Client) {
+		log.Printf("Підключено до брокера MQTT %s", config.MQTTBroker)
+		state.mu.RLock()
+		active := activeAlertTypes(state)
+		regions := make(map[string]string, len(state.AlertRegions))
+		for alertType, region := range state.AlertRegions {
+			regions[alertType] = region
+		}
+		state.mu.RUnlock()
+		p.publish("status", "online")
+		for _, alertType := range active {
+			p.publish(alertType, "active")
+		}
+		p.publishAny(len(active) > 0)
+		p.publishDiscovery(active, regions)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		logWarn("Втрачено звʼязок з брокером MQTT: %v, підключаємось знову", err)
+	})
+
+	p.client = mqtt.NewClient(opts)
+	p.client.Connect()
+	return p
+}
+
+// Публікує зміну стану тривоги: <topic>/<type> - active/clear, <topic>/any - 1/0
+func (p *mqttPublisher) publishEvent(event AlertEvent) {
+	if p == nil {
+		return
+	}
+	switch event.Kind {
+	case "start":
+		p.publish(event.AlertType, "active")
+	case "end":
+		p.publish(event.AlertType, "clear")
+	default:
+		return
+	}
+	p.publishAny(len(event.Active) > 0)
+	p.publishDiscoveryEvent(event)
+}
+
+func (p *mqttPublisher) publishAny(active bool) {
+	if active {
+		p.publish("any", "1")
+	} else {
+		p.publish("any", "0")
+	}
+}
+
+// Публікує повідомлення без очікування, щоб недоступний брокер не затримував основний цикл.
+// Поки звʼязку немає, повідомлення чекають у черзі клієнта
+func (p *mqttPublisher) publish(subtopic, payload string) {
+	p.publishTopic(p.topic+"/"+subtopic, payload)
+}
+
+func (p *mqttPublisher) publishTopic(topic, payload string) {
+	token := p.client.Publish(topic, 1, true, payload)
+	go func() {
+		if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			logWarn("Попередження: не вдалося опублікувати %s у MQTT: %v", topic, token.Error())
+		}
+	}()
+}
+
+// Публікує статус offline і відключається від брокера. З mqtt_ha_cleanup також
+// видаляє конфігурацію датчиків Home Assistant
+func (p *mqttPublisher) close() {
+	if p == nil {
+		return
+	}
+	if p.client.IsConnected() {
+		p.clearDiscovery()
+		p.client.Publish(p.topic+"/status", 1, true, "offline").WaitTimeout(time.Second)
+	}
+	p.client.Disconnect(250)
+}
+
+// haDiscovery публікує датчики binary_sensor для автоматичного виявлення у Home Assistant:
+// по одному на кожен тип тривоги та на кожен регіон, у якому була тривога
+type haDiscovery struct {
+	prefix  string // Префікс топіків виявлення Home Assistant
+	node    string // Ідентифікатор пристрою у Home Assistant
+	device  string // Назва пристрою
+	cleanup bool
+	types   []string
+	labels  map[string]string
+
+	mu        sync.Mutex
+	announced map[string]bool            // Датчики, для яких опубліковано конфігурацію
+	regions   map[string]map[string]bool // Активні типи тривог за регіоном
+}
+
+// Конфігурація датчика для Home Assistant. Стани ON/OFF та доступність online/offline
+// збігаються зі значеннями Home Assistant за замовчуванням
+type haSensorConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	DeviceClass       string   `json:"device_class"`
+	Device            haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+func newHADiscovery(config *Config, clientID string) *haDiscovery {
+	prefix := config.MQTTHAPrefix
+	if prefix == "" {
+		prefix = "homeassistant" // Значення за замовчуванням
+	}
+	device := "Signal"
+	if config.InstanceName != "" {
+		device += " " + config.InstanceName
+	}
+	return &haDiscovery{
+		prefix:    prefix,
+		node:      haObjectID(clientID),
+		device:    device,
+		cleanup:   config.MQTTHACleanup,
+		types:     monitoredAlertTypes(config),
+		labels:    config.TypeLabels,
+		announced: make(map[string]bool),
+		regions:   make(map[string]map[string]bool),
+	}
+}
+
+// Типи тривог зі звуком у audio_files та зі списку audio_types_whitelist
+func monitoredAlertTypes(config *Config) []string {
+	types := slices.Clone(config.AudioWhitelist)
+	for alertType := range config.AudioFiles {
+		types = append(types, alertType)
+	}
+	slices.Sort(types)
+	return slices.Compact(types)
+}
+
+// Перетворює назву на ідентифікатор, допустимий у топіках виявлення: латинські літери, цифри та _
+func haObjectID(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, name)
+}
+
+func haTypeObject(alertType string) string {
+	return "type_" + haObjectID(alertType)
+}
+
+// Назви регіонів зазвичай кирилицею, тому ідентифікатор будується з хешу назви
+func haRegionObject(region string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(region))
+	return fmt.Sprintf("region_%08x", hash.Sum32())
+}
+
+func (d *haDiscovery) configTopic(object string) string {
+	return d.prefix + "/binary_sensor/" + d.node + "/" + object + "/config"
+}
+
+func (p *mqttPublisher) haStateTopic(object string) string {
+	return p.topic + "/ha/" + object
+}
+
+func haState(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// Публікує конфігурацію датчика. Викликається під d.mu
+func (p *mqttPublisher) announce(object, name string) {
+	d := p.discovery
+	payload, err := json.Marshal(haSensorConfig{
+		Name:              name,
+		UniqueID:          d.node + "_" + object,
+		StateTopic:        p.haStateTopic(object),
+		AvailabilityTopic: p.topic + "/status",
+		DeviceClass:       "safety",
+		Device:            haDevice{Identifiers: []string{d.node}, Name: d.device},
+	})
+	if err != nil {
+		logError("Помилка формування конфігурації датчика %s: %v", object, err)
+		return
+	}
+	p.publishTopic(d.configTopic(object), string(payload))
+	d.announced[object] = true
+}
+
+// Публікує датчик типу тривоги та його стан. Викликається під d.mu
+func (p *mqttPublisher) publishTypeState(alertType string, on bool) {
+	object := haTypeObject(alertType)
+	if !p.discovery.announced[object] {
+		label := alertType
+		if custom, ok := p.discovery.labels[alertType]; ok {
+			label = custom
+		}
+		p.announce(object, label)
+	}
+	p.publishTopic(p.haStateTopic(object), haState(on))
+}
+
+// Публікує датчик регіону та його стан: ON, поки в регіоні активна хоча б одна тривога. Викликається під d.mu
+func (p *mqttPublisher) publishRegionState(region string) {
+	object := haRegionObject(region)
+	if !p.discovery.announced[object] {
+		p.announce(object, region)
+	}
+	p.publishTopic(p.haStateTopic(object), haState(len(p.discovery.regions[region]) > 0))
+}
+
+// Після (пере)підключення публікує конфігурацію всіх відомих датчиків та їх поточний стан.
+// regions - регіони активних тривог за типом
+func (p *mqttPublisher) publishDiscovery(active []string, regions map[string]string) {
+	d := p.discovery
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.announced)
+
+	types := slices.Concat(d.types, active)
+	slices.Sort(types)
+	for _, alertType := range slices.Compact(types) {
+		p.publishTypeState(alertType, slices.Contains(active, alertType))
+	}
+
+	// Регіони, де тривоги вже закінчились, лишаються відомими зі станом OFF
+	for _, types := range d.regions {
+		clear(types)
+	}
+	for alertType, region := range regions {
+		if region == "" {
+			continue
+		}
+		if d.regions[region] == nil {
+			d.regions[region] = make(map[string]bool)
+		}
+		d.regions[region][alertType] = true
+	}
+	for region := range d.regions {
+		p.publishRegionState(region)
+	}
+}
+
+// Публікує стан датчиків типу та регіону події
+func (p *mqttPublisher) publishDiscoveryEvent(event AlertEvent) {
+	d := p.discovery
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	on := event.Kind == "start"
+	p.publishTypeState(event.AlertType, on)
+	if event.Region == "" {
+		return
+	}
+	if d.regions[event.Region] == nil {
+		d.regions[event.Region] = make(map[string]bool)
+	}
+	if on {
+		d.regions[event.Region][event.AlertType] = true
+	} else {
+		delete(d.regions[event.Region], event.AlertType)
+	}
+	p.publishRegionState(event.Region)
+}
+
+// Видаляє конфігурацію опублікованих датчиків порожніми повідомленнями, якщо вказано mqtt_ha_cleanup
+func (p *mqttPublisher) clearDiscovery() {
+	d := p.discovery
+	if d == nil || !d.cleanup {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var tokens []mqtt.Token
+	for object := range d.announced {
+		tokens = append(tokens, p.client.Publish(d.configTopic(object), 1, true, ""))
+	}
+	for _, token := range tokens {
+		token.WaitTimeout(time.Second)
+	}
+	clear(d.announced)
+}
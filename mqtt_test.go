@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Завершений токен публікації без помилки
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (doneToken) Error() error { return nil }
+
+// Клієнт MQTT, що запамʼятовує останнє повідомлення кожного топіка
+type recordingClient struct {
+	mqtt.Client
+	mu       sync.Mutex
+	messages map[string]string
+}
+
+func (c *recordingClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages[topic] = payload.(string)
+	return doneToken{}
+}
+
+func (c *recordingClient) IsConnected() bool       { return true }
+func (c *recordingClient) Disconnect(quiesce uint) {}
+
+func (c *recordingClient) message(topic string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	payload, ok := c.messages[topic]
+	return payload, ok
+}
+
+func TestHADiscovery(t *testing.T) {
+	config := &Config{
+		MQTTHADiscovery: true,
+		MQTTHACleanup:   true,
+		AudioFiles:      map[string]Playlist{"AIR": {"air.mp3"}, "ARTILLERY": {"artillery.mp3"}},
+		TypeLabels:      map[string]string{"AIR": "Повітряна тривога"},
+	}
+	client := &recordingClient{messages: make(map[string]string)}
+	p := &mqttPublisher{client: client, topic: "signal", discovery: newHADiscovery(config, "signal-app")}
+
+	// Після підключення зʼявляються датчики всіх типів з audio_files
+	p.publishDiscovery(nil, nil)
+	airConfig := "homeassistant/binary_sensor/signal_app/type_air/config"
+	payload, ok := client.message(airConfig)
+	if !ok {
+		t.Fatalf("no discovery config published to %s", airConfig)
+	}
+	var sensor haSensorConfig
+	if err := json.Unmarshal([]byte(payload), &sensor); err != nil {
+		t.Fatalf("discovery config: %v", err)
+	}
+	if sensor.Name != "Повітряна тривога" || sensor.StateTopic != "signal/ha/type_air" || sensor.AvailabilityTopic != "signal/status" {
+		t.Errorf("discovery config = %+v", sensor)
+	}
+	if state, _ := client.message("signal/ha/type_artillery"); state != "OFF" {
+		t.Errorf("ARTILLERY state = %q, want OFF", state)
+	}
+
+	// Регіон увімкнений, поки в ньому активна хоча б одна тривога
+	region := haRegionObject("Київ")
+	p.publishEvent(AlertEvent{Kind: "start", AlertType: "AIR", Region: "Київ", Active: []string{"AIR"}})
+	p.publishEvent(AlertEvent{Kind: "start", AlertType: "ARTILLERY", Region: "Київ", Active: []string{"AIR", "ARTILLERY"}})
+	if state, _ := client.message("signal/ha/type_air"); state != "ON" {
+		t.Errorf("AIR state = %q, want ON", state)
+	}
+	if _, ok := client.message("homeassistant/binary_sensor/signal_app/" + region + "/config"); !ok {
+		t.Errorf("no discovery config for region %s", region)
+	}
+	p.publishEvent(AlertEvent{Kind: "end", AlertType: "AIR", Region: "Київ", Active: []string{"ARTILLERY"}})
+	if state, _ := client.message("signal/ha/" + region); state != "ON" {
+		t.Errorf("region state = %q after one of two alerts ended, want ON", state)
+	}
+	p.publishEvent(AlertEvent{Kind: "end", AlertType: "ARTILLERY", Region: "Київ"})
+	if state, _ := client.message("signal/ha/" + region); state != "OFF" {
+		t.Errorf("region state = %q after all alerts ended, want OFF", state)
+	}
+
+	// Нові типи отримують датчик при першій події
+	p.publishEvent(AlertEvent{Kind: "start", AlertType: "CHEMICAL", Active: []string{"CHEMICAL"}})
+	if state, _ := client.message("signal/ha/type_chemical"); state != "ON" {
+		t.Errorf("CHEMICAL state = %q, want ON", state)
+	}
+
+	// mqtt_ha_cleanup видаляє конфігурацію при завершенні роботи
+	p.close()
+	for _, topic := range []string{airConfig, "homeassistant/binary_sensor/signal_app/" + region + "/config", "homeassistant/binary_sensor/signal_app/type_chemical/config"} {
+		if payload, _ := client.message(topic); payload != "" {
+			t.Errorf("%s = %q after close, want empty", topic, payload)
+		}
+	}
+	if status, _ := client.message("signal/status"); status != "offline" {
+		t.Errorf("status = %q after close, want offline", status)
+	}
+}
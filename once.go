@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Коди завершення режиму -once
+const (
+	onceExitNoAlerts = 0
+	onceExitError    = 1
+	onceExitActive   = 10
+)
+
+// Скільки чекати завершення сповіщень, що надсилаються у фоні, перед виходом з режиму -once
+const onceNotifyTimeout = 30 * time.Second
+
+// Фонові сповіщення (Telegram, пошта, webhook, команди), яких треба дочекатися перед виходом
+var notifications sync.WaitGroup
+
+// Виконує один цикл роботи: обробляє відповідь сервера, відтворює звуки подій і зберігає стан.
+// Повертає код завершення програми
+func runOnce(settings *atomic.Pointer[Settings], state *State, result FetchResult, statePath string) int {
+	if result.Err != nil {
+		logError("Помилка отримання даних: %v", result.Err)
+		return onceExitError
+	}
+
+	current := settings.Load()
+	evaluator := &Evaluator{
+		config:    current.Config,
+		state:     state,
+		location:  current.Location,
+		statePath: statePath,
+		firstPoll: true,
+	}
+	events := evaluator.process(result)
+
+	// Звуки відтворюються тим самим програвачем, що й у звичайному режимі
+	queue := make(chan AlertEvent)
+	played := make(chan struct{})
+	go runPlayer(settings, queue, played)
+	for _, event := range events {
+		queue <- event
+		<-played
+	}
+	close(queue)
+
+	done := make(chan struct{})
+	go func() {
+		notifications.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(onceNotifyTimeout):
+		log.Printf("Не всі сповіщення надіслано за %s", onceNotifyTimeout)
+	}
+
+	state.mu.Lock()
+	saveState(state, statePath)
+	active := activeAlertTypes(state)
+	state.mu.Unlock()
+
+	if len(active) > 0 {
+		log.Printf("Активні тривоги: %v", active)
+		return onceExitActive
+	}
+	log.Println("Активних тривог немає")
+	return onceExitNoAlerts
+}
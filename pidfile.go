@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Записує PID поточного процесу у pid_file. Якщо файл вже існує і вказаний у ньому процес
+// працює, повертає помилку: два екземпляри заважали б один одному (динамік, state.json).
+// Файл від процесу, що вже завершився, перезаписується
+func acquirePIDFile(path string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("програма вже запущена (PID %d, файл %s)", pid, path)
+		}
+		log.Printf("Застарілий PID файл %s (%s), перезаписуємо", path, strings.TrimSpace(string(data)))
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return fmt.Errorf("не вдалося створити PID файл %s", path)
+}
+
+// Видаляє PID файл при завершенні роботи
+func releasePIDFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logWarn("Не вдалося видалити PID файл %s: %v", path, err)
+	}
+}
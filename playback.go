@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Керує поточним відтворенням, щоб важливіша подія могла його перервати
+type playbackManager struct {
+	mu          sync.Mutex
+	ctrl        *beep.Ctrl
+	interrupted chan struct{}
+	cancelled   bool // Решту звуків поточної події пропускаємо після переривання
+}
+
+var playback = &playbackManager{}
+
+// Готує відтворення звуків нової події
+func (m *playbackManager) reset() {
+	m.mu.Lock()
+	m.cancelled = false
+	m.mu.Unlock()
+}
+
+// Відтворює потік і чекає його завершення або переривання.
+// Повертає false, якщо відтворення перервано
+func (m *playbackManager) play(streamer beep.Streamer) bool {
+	done := make(chan struct{})
+	ctrl := &beep.Ctrl{Streamer: beep.Seq(streamer, beep.Callback(func() {
+		close(done)
+	}))}
+
+	m.mu.Lock()
+	if m.cancelled {
+		m.mu.Unlock()
+		return false
+	}
+	interrupted := make(chan struct{})
+	m.ctrl = ctrl
+	m.interrupted = interrupted
+	m.mu.Unlock()
+
+	speaker.Play(ctrl)
+
+	finished := true
+	select {
+	case <-done:
+	case <-interrupted:
+		finished = false
+	}
+
+	m.mu.Lock()
+	if m.ctrl == ctrl {
+		m.ctrl = nil
+		m.interrupted = nil
+	}
+	m.mu.Unlock()
+	return finished
+}
+
+// Зупиняє поточний звук і пропускає решту звуків поточної події
+func (m *playbackManager) interrupt() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancelled = true
+	if m.ctrl == nil {
+		return
+	}
+	// Ctrl без потоку повідомляє динаміку про завершення, і той прибирає його з мікшера
+	speaker.Lock()
+	m.ctrl.Streamer = nil
+	speaker.Unlock()
+	close(m.interrupted)
+	m.ctrl = nil
+	m.interrupted = nil
+}
+
+// Пріоритет події для переривання відтворення. Початок тривоги важливіший за інші звуки,
+// серед типів подій вищий пріоритет має той, що стоїть раніше у alert_priority
+func eventPriority(config *Config, event AlertEvent) int {
+	if event.Kind != "start" {
+		return 0
+	}
+	return len(alertPriorityOrder(config)) - alertTypeRank(config, event.AlertType) + 1
+}
+
+// Найбільша кількість подій у черзі відтворення, крім початку і кінця тривоги
+const maxAudioQueue = 8
+
+// Додає подію у чергу відтворення. Повторні сигнали, сигнали "тривога ще триває" та покращення
+// ситуації не додаються, якщо черга заповнена, а з audio_queue_dedupe - ще й якщо такий самий
+// сигнал вже чекає або звучить. Початок і кінець тривоги додаються завжди, бо разом з ними
+// надсилаються сповіщення
+func enqueueAudioEvent(config *Config, queue []AlertEvent, event AlertEvent, playing *AlertEvent) []AlertEvent {
+	if event.Kind == "start" || event.Kind == "end" {
+		return append(queue, event)
+	}
+	if config.AudioQueueDedupe {
+		sameSound := func(other AlertEvent) bool {
+			return other.Kind == event.Kind && other.AlertType == event.AlertType
+		}
+		if playing != nil && sameSound(*playing) {
+			log.Printf("Сигнал %s (%s) вже звучить, повтор пропущено", event.Kind, event.AlertType)
+			return queue
+		}
+		for _, waiting := range queue {
+			if sameSound(waiting) {
+				log.Printf("Сигнал %s (%s) вже у черзі, повтор пропущено", event.Kind, event.AlertType)
+				return queue
+			}
+		}
+	}
+	if len(queue) >= maxAudioQueue {
+		logWarn("Черга відтворення заповнена (%d), сигнал %s (%s) пропущено", len(queue), event.Kind, event.AlertType)
+		return queue
+	}
+	return append(queue, event)
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package main
+
+import "os"
+
+// Чи існує процес з вказаним PID. На Windows FindProcess повертає помилку, якщо процесу немає
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
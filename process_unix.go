@@ -0,0 +1,14 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Чи існує процес з вказаним PID. Сигнал 0 лише перевіряє процес, не впливаючи на нього
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+)
+
+// QuietHours - нічний проміжок, у який звуки приглушуються
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Перевіряє, чи потрапляє час у проміжок quiet_hours з урахуванням переходу через північ
+func inQuietHours(config *Config, location *time.Location, now time.Time) bool {
+	if config.QuietHours == nil || config.QuietHours.Start == "" || config.QuietHours.End == "" {
+		return false
+	}
+	start, err := time.Parse("15:04", config.QuietHours.Start)
+	if err != nil {
+		logError("Помилка парсингу quiet_hours.start: %v", err)
+		return false
+	}
+	end, err := time.Parse("15:04", config.QuietHours.End)
+	if err != nil {
+		logError("Помилка парсингу quiet_hours.end: %v", err)
+		return false
+	}
+
+	local := now.In(location)
+	current := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from <= to {
+		return current >= from && current < to
+	}
+	// Проміжок на кшталт 23:00-07:00 переходить через північ
+	return current >= from || current < to
+}
+
+// Визначає, чи приглушити звук події у тихі години.
+// silent - приглушуються всі звуки, repeat_only - лише повторні сигнали
+func quietSuppresses(config *Config, location *time.Location, kind string, now time.Time) bool {
+	if !inQuietHours(config, location, now) {
+		return false
+	}
+	switch config.QuietMode {
+	case "silent":
+		return true
+	case "repeat_only":
+		return kind == "repeat" || kind == "chime"
+	default:
+		return false
+	}
+}
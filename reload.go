@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Settings - налаштування, які можна замінити під час роботи за сигналом SIGHUP
+type Settings struct {
+	Config   *Config
+	Location *time.Location
+	Client   *http.Client
+	Source   AlertSource
+}
+
+// Повертає інтервал запитів до сервера
+func requestIntervalFor(config *Config) time.Duration {
+	if config.RequestIntervalSec <= 0 {
+		return 30 * time.Second // Значення за замовчуванням
+	}
+	return time.Duration(config.RequestIntervalSec) * time.Second
+}
+
+// Найбільша пауза між запитами при помилках поспіль
+func maxBackoffFor(config *Config) time.Duration {
+	if config.MaxBackoffSec <= 0 {
+		return 5 * time.Minute // Значення за замовчуванням
+	}
+	return time.Duration(config.MaxBackoffSec) * time.Second
+}
+
+// Повертає інтервал запитів з урахуванням активних тривог: під час тривоги
+// використовується active_request_interval_sec, якщо він вказаний
+func pollInterval(config *Config, active bool) time.Duration {
+	if active && config.ActiveIntervalSec > 0 {
+		return time.Duration(config.ActiveIntervalSec) * time.Second
+	}
+	return requestIntervalFor(config)
+}
+
+// Повторно читає конфігурацію і перевіряє її. Залежні значення (часова зона, HTTP клієнт)
+// створюються заново. При помилці повертає nil, а програма продовжує працювати зі старими налаштуваннями
+func reloadSettings(configPath string) (*Settings, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("помилки у конфігурації:\n%w", err)
+	}
+	location, err := time.LoadLocation(config.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("помилка завантаження часової зони: %w", err)
+	}
+	if err := validateAudioFiles(config); err != nil {
+		return nil, fmt.Errorf("помилка перевірки аудіофайлів:\n%w", err)
+	}
+	client := newHTTPClient(config)
+	source, err := newAlertSource(config, client)
+	if err != nil {
+		return nil, err
+	}
+	// Відповіді, розібрані за старими налаштуваннями, більше не актуальні
+	conditional.reset()
+	return &Settings{Config: config, Location: location, Client: client, Source: source}, nil
+}
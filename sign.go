@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Підтримувані алгоритми підпису запитів
+var hmacSchemes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Шаблон підписуваного рядка за замовчуванням
+const defaultHMACMessage = "{method}\n{path}\n{timestamp}"
+
+// Обчислює підпис HMAC для запиту. Шаблон може містити {method}, {path}, {query} та {timestamp}
+func computeSignature(config *Config, method, path, query, timestamp string) (string, error) {
+	scheme := config.HMACScheme
+	if scheme == "" {
+		scheme = "sha256" // Значення за замовчуванням
+	}
+	newHash, ok := hmacSchemes[strings.ToLower(scheme)]
+	if !ok {
+		return "", fmt.Errorf("невідомий алгоритм підпису: %s", scheme)
+	}
+
+	template := config.HMACMessage
+	if template == "" {
+		template = defaultHMACMessage
+	}
+	message := strings.NewReplacer(
+		"{method}", method,
+		"{path}", path,
+		"{query}", query,
+		"{timestamp}", timestamp,
+	).Replace(template)
+
+	mac := hmac.New(newHash, []byte(config.HMACSecret))
+	mac.Write([]byte(message))
+	sum := mac.Sum(nil)
+
+	if config.HMACEncoding == "base64" {
+		return base64.StdEncoding.EncodeToString(sum), nil
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// Додає до запиту підпис та мітку часу, якщо підпис налаштовано
+func signRequest(req *http.Request, config *Config, now time.Time) error {
+	if config.HMACSecret == "" {
+		return nil
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature, err := computeSignature(config, req.Method, req.URL.EscapedPath(), req.URL.RawQuery, timestamp)
+	if err != nil {
+		return err
+	}
+
+	signatureHeader := config.HMACHeader
+	if signatureHeader == "" {
+		signatureHeader = "X-Signature" // Значення за замовчуванням
+	}
+	timestampHeader := config.HMACTimeHeader
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp" // Значення за замовчуванням
+	}
+	req.Header.Set(signatureHeader, signature)
+	req.Header.Set(timestampHeader, timestamp)
+	return nil
+}
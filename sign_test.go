@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name            string
+		url             string
+		config          Config
+		signatureHeader string
+		want            string
+	}{
+		{
+			name:            "defaults",
+			url:             "https://example.com/api/alerts",
+			config:          Config{HMACSecret: "secret"},
+			signatureHeader: "X-Signature",
+			want:            "b07df36015cdf27b47e472a89df3bf8fb39c9fa2951833bd9ef9055f4137a08d",
+		},
+		{
+			name:            "sha1",
+			url:             "https://example.com/api/alerts",
+			config:          Config{HMACSecret: "secret", HMACScheme: "SHA1"},
+			signatureHeader: "X-Signature",
+			want:            "a3711c6f0148b8d12063f64468490df57d34c334",
+		},
+		{
+			name: "sha512 base64 with query and custom header",
+			url:  "https://example.com/api/alerts?region=1",
+			config: Config{
+				HMACSecret:   "secret",
+				HMACScheme:   "sha512",
+				HMACEncoding: "base64",
+				HMACMessage:  "{method} {path} {query} {timestamp}",
+				HMACHeader:   "X-Api-Signature",
+			},
+			signatureHeader: "X-Api-Signature",
+			want:            "xOF6PngIuKmU8yTI1aRxq0Wl8cH/Ei6ay8S5A4/zKs0VQ96sgevtxf8IrXCUsOkq7JRSKPMKGWy6BUimjk8wRg==",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signRequest(req, &tt.config, now); err != nil {
+				t.Fatalf("signRequest: %v", err)
+			}
+			if got := req.Header.Get(tt.signatureHeader); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.signatureHeader, got, tt.want)
+			}
+			if got := req.Header.Get("X-Timestamp"); got != "1714557600" {
+				t.Errorf("X-Timestamp = %q, want 1714557600", got)
+			}
+		})
+	}
+}
+
+func TestSignRequestErrors(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/api/alerts", nil)
+	if err := signRequest(req, &Config{}, time.Now()); err != nil || req.Header.Get("X-Signature") != "" {
+		t.Errorf("request signed without hmac_secret: %v, %q", err, req.Header.Get("X-Signature"))
+	}
+	if err := signRequest(req, &Config{HMACSecret: "secret", HMACScheme: "md5"}, time.Now()); err == nil {
+		t.Error("unknown hmac_scheme accepted")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// SimSnapshot - одна відповідь сервера у файлі симуляції
+type SimSnapshot struct {
+	Time    string   `json:"time"` // Час сервера, якщо регіони його не містять
+	Regions []Region `json:"regions"`
+}
+
+// Simulator видає відповіді з файлу по черзі замість запитів до сервера
+type Simulator struct {
+	mu        sync.Mutex
+	snapshots []SimSnapshot
+	pos       int
+	interval  time.Duration
+}
+
+// Режим симуляції, nil - звичайна робота з сервером
+var simulator *Simulator
+
+var errSimulationDone = errors.New("відповіді симуляції закінчились")
+
+func loadSimulator(path string, interval time.Duration) (*Simulator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []SimSnapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("файл %s не містить жодної відповіді", path)
+	}
+	return &Simulator{snapshots: snapshots, interval: interval}, nil
+}
+
+// Повертає події наступної відповіді або errSimulationDone, якщо відповіді закінчились
+func (s *Simulator) next(config *Config) ([]Alert, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pos >= len(s.snapshots) {
+		return nil, "", errSimulationDone
+	}
+	snapshot := s.snapshots[s.pos]
+	s.pos++
+
+	alerts, lastUpdate, err := alertsFromRegions(snapshot.Regions, config)
+	if lastUpdate == "" {
+		lastUpdate = snapshot.Time
+	}
+	return alerts, lastUpdate, err
+}
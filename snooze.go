@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Тривалість тиші для повторних сигналів за замовчуванням
+const defaultSnoozeMin = 30
+
+// snoozeTracker памʼятає, чи діє тиша, щоб записати у лог її початок і кінець
+type snoozeTracker struct {
+	mu     sync.Mutex
+	active bool
+	until  time.Time
+}
+
+var snooze = &snoozeTracker{}
+
+// Чи вимкнено зараз повторні сигнали. Тиша діє snooze_min хвилин від часу зміни файлу snooze_file,
+// тож її вмикає (або подовжує) команда touch, а після закінчення файл можна не видаляти
+func (s *snoozeTracker) snoozed(config *Config, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var until time.Time
+	if config.SnoozeFile != "" {
+		if info, err := os.Stat(config.SnoozeFile); err == nil {
+			minutes := config.SnoozeMin
+			if minutes <= 0 {
+				minutes = defaultSnoozeMin
+			}
+			until = info.ModTime().Add(time.Duration(minutes) * time.Minute)
+		}
+	}
+	active := now.Before(until)
+
+	switch {
+	case active && (!s.active || !until.Equal(s.until)):
+		log.Printf("Повторні сигнали вимкнено до %s", until.Format("2006-01-02 15:04:05"))
+	case !active && s.active:
+		log.Println("Тишу завершено, повторні сигнали знову увімкнено")
+	}
+	s.active, s.until = active, until
+	return active
+}
+
+// Завершує тишу: видаляє snooze_file
+func (s *snoozeTracker) release(config *Config) {
+	if err := os.Remove(config.SnoozeFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logWarn("Попередження: не вдалося видалити snooze_file: %v", err)
+	}
+	s.mu.Lock()
+	s.active, s.until = false, time.Time{}
+	s.mu.Unlock()
+}
+
+// З snooze_skip_all_clear відбій, що припадає на тишу, не лунає: користувач вже знає про тривогу.
+// Подія закінчення записується і надсилається як завжди, а тиша завершується разом з тривогою
+func skipSnoozedAllClear(events []AlertEvent, config *Config, now time.Time) {
+	if !config.SnoozeSkipAllClear {
+		return
+	}
+	for i := range events {
+		if !events[i].AllClear || !snooze.snoozed(config, now) {
+			continue
+		}
+		events[i].AllClear = false
+		events[i].Snoozed = true
+		snooze.release(config)
+		log.Printf("Подія %s закінчилась під час тиші: відбій не лунає, тишу завершено", events[i].AlertType)
+	}
+}
+
+// Час останньої зміни snooze_file. Зміна файлу після появи тривоги підтверджує її для escalate_after_sec
+func snoozeTouchedAt(config *Config) (time.Time, bool) {
+	if config.SnoozeFile == "" {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(config.SnoozeFile)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnoozeSkipsAllClear(t *testing.T) {
+	started := time.Now().UTC().Add(-10 * time.Minute).Format(time.RFC3339)
+	active := FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: started}}, LastUpdate: started}
+	clear := FetchResult{LastUpdate: time.Now().UTC().Format(time.RFC3339)}
+
+	for _, tt := range []struct {
+		name         string
+		skip         bool
+		snoozed      bool
+		wantAllClear bool
+	}{
+		{"snoozed and enabled", true, true, false},
+		{"snoozed but disabled", false, true, true},
+		{"enabled without snooze", true, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			snoozeFile := filepath.Join(t.TempDir(), "snooze")
+			if tt.snoozed {
+				if err := os.WriteFile(snoozeFile, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			e := newTestEvaluator(t, &Config{SnoozeFile: snoozeFile, SnoozeSkipAllClear: tt.skip})
+			e.process(active)
+
+			events := e.process(clear)
+			if len(events) != 1 || events[0].Kind != "end" {
+				t.Fatalf("clear poll events = %v, want [end:AIR]", eventKinds(events))
+			}
+			if events[0].AllClear != tt.wantAllClear || events[0].Snoozed == tt.wantAllClear {
+				t.Errorf("end event all clear %v, snoozed %v; want all clear %v", events[0].AllClear, events[0].Snoozed, tt.wantAllClear)
+			}
+
+			// Тиша завершується лише разом з пропущеним відбоєм
+			_, err := os.Stat(snoozeFile)
+			if released := tt.snoozed && os.IsNotExist(err); released != (tt.skip && tt.snoozed) {
+				t.Errorf("snooze file released = %v", released)
+			}
+		})
+	}
+}
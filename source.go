@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AlertSource - джерело даних про тривоги. Повертає активні тривоги та час оновлення даних
+type AlertSource interface {
+	Fetch(ctx context.Context) ([]Alert, string, error)
+}
+
+// Типи джерел для source_type
+const (
+	sourceHTTP = "http"
+	sourceFile = "file"
+)
+
+// httpJSONSource опитує API сервера (api_url або api_urls)
+type httpJSONSource struct {
+	client *http.Client
+	config *Config
+}
+
+func (s *httpJSONSource) Fetch(ctx context.Context) ([]Alert, string, error) {
+	return fetchAlerts(ctx, s.client, s.config)
+}
+
+// fileSource читає тривоги з локального файлу у форматі fallback_alert_file
+type fileSource struct {
+	path string
+}
+
+func (s *fileSource) Fetch(ctx context.Context) ([]Alert, string, error) {
+	return readFallbackAlerts(s.path)
+}
+
+// simulatedSource видає відповіді з файлу симуляції
+type simulatedSource struct {
+	simulator *Simulator
+	config    *Config
+}
+
+func (s *simulatedSource) Fetch(ctx context.Context) ([]Alert, string, error) {
+	return s.simulator.next(s.config)
+}
+
+// Створює джерело тривог за source_type. У режимі симуляції source_type не враховується
+func newAlertSource(config *Config, client *http.Client) (AlertSource, error) {
+	if simulator != nil {
+		return &simulatedSource{simulator: simulator, config: config}, nil
+	}
+	switch config.SourceType {
+	case "", sourceHTTP:
+		return &httpJSONSource{client: client, config: config}, nil
+	case sourceFile:
+		return &fileSource{path: config.SourceFile}, nil
+	default:
+		return nil, fmt.Errorf("невідомий source_type %q", config.SourceType)
+	}
+}
+
+// Назва джерела для логів загального стану: файл, симуляція або адреси API
+func sourceName(config *Config) string {
+	if simulator != nil {
+		return "симуляція"
+	}
+	if config.SourceType == sourceFile {
+		return config.SourceFile
+	}
+	urls := make([]string, 0, len(config.APIURLs))
+	for _, endpoint := range apiEndpoints(config) {
+		urls = append(urls, endpoint.URL)
+	}
+	return strings.Join(urls, ", ")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+)
+
+// Перевіряє час оновлення даних від сервера: попереджає, якщо він повернувся назад
+// (збій у провайдера) або не змінювався довше за stale_data_min (дані можуть бути застарілими)
+func (e *Evaluator) checkLastUpdate(previous, current string, now time.Time) {
+	config := e.config
+	if previous != "" && current != previous {
+		previousTime, errPrevious := parseAPITime(previous)
+		currentTime, errCurrent := parseAPITime(current)
+		if errPrevious == nil && errCurrent == nil && currentTime.Before(previousTime) {
+			logEvent(slog.LevelWarn, fmt.Sprintf("Попередження: час оновлення даних зменшився: %s -> %s, можливий збій у провайдера", previous, current),
+				"event", "last_update_backward", "previous", previous, "last_update", current)
+		}
+	}
+
+	if current != previous || e.lastUpdateChanged.IsZero() {
+		if e.staleReported {
+			log.Printf("Дані знову оновлюються, час оновлення: %s", current)
+			e.staleReported = false
+		}
+		e.lastUpdateChanged = now
+		return
+	}
+	if config.StaleDataMin <= 0 || e.staleReported {
+		return
+	}
+
+	unchanged := now.Sub(e.lastUpdateChanged)
+	if unchanged < time.Duration(config.StaleDataMin)*time.Minute {
+		return
+	}
+	e.staleReported = true
+	text := fmt.Sprintf("Попередження: час оновлення даних %s не змінювався %s, дані можуть бути застарілими", current, formatDuration(unchanged))
+	logEvent(slog.LevelWarn, text, "event", "stale_data", "last_update", current, "unchanged_sec", int64(unchanged.Seconds()))
+	if config.StaleDataNotify {
+		sendTelegram(config, text)
+	}
+}
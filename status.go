@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// StatusResponse - відповідь на запит /status
+type StatusResponse struct {
+	State            *State       `json:"state"`
+	LastFetchSuccess time.Time    `json:"last_fetch_success"`
+	Timers           []AlertTimer `json:"timers"` // Таймери активних подій
+
+	// Стан окремих джерел, до яких уже були запити
+	Sources []SourceStatus `json:"sources,omitempty"`
+}
+
+// SourceStatus - стан окремого джерела з api_urls
+type SourceStatus struct {
+	URL                 string    `json:"url"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at"`
+	LatencyMs           int64     `json:"latency_ms"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// Перетворює стан джерел у формат відповіді /status
+func sourceStatuses(config *Config) []SourceStatus {
+	var statuses []SourceStatus
+	for _, health := range endpointHealth.snapshot(config) {
+		statuses = append(statuses, SourceStatus{
+			URL:                 health.URL,
+			LastSuccess:         health.LastSuccess,
+			LastError:           health.LastError,
+			LastErrorAt:         health.LastErrorAt,
+			LatencyMs:           health.Latency.Milliseconds(),
+			ConsecutiveFailures: health.ConsecutiveFailures,
+		})
+	}
+	return statuses
+}
+
+// Запускає HTTP сервери стану (status_listen_addr) та метрик (metrics_listen_addr).
+// Якщо адреси збігаються, обидва працюють на одному сервері. Сервери зупиняються разом з ctx
+func startStatusServer(ctx context.Context, settings *atomic.Pointer[Settings], state *State, requestInterval time.Duration) {
+	config := settings.Load().Config
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	if config.StatusListenAddr != "" {
+		registerStatusHandlers(muxFor(config.StatusListenAddr), settings, state, requestInterval)
+	}
+	if config.MetricsListenAddr != "" {
+		muxFor(config.MetricsListenAddr).Handle("/metrics", promhttp.Handler())
+	}
+
+	for addr, mux := range muxes {
+		serveHTTP(ctx, addr, mux)
+	}
+}
+
+func registerStatusHandlers(mux *http.ServeMux, settings *atomic.Pointer[Settings], state *State, requestInterval time.Duration) {
+	// Програма вважається живою, якщо останній успішний запит був не пізніше двох інтервалів опитування
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		state.mu.RLock()
+		lastSuccess := state.LastFetchOK
+		state.mu.RUnlock()
+
+		if lastSuccess.IsZero() || time.Since(lastSuccess) > 2*requestInterval {
+			http.Error(w, "stale", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		snapshot := state.Snapshot()
+		config := settings.Load().Config
+		response := StatusResponse{
+			State:            snapshot,
+			LastFetchSuccess: snapshot.LastFetchOK,
+			Timers:           alertTimers(snapshot, config, time.Now().UTC()),
+			Sources:          sourceStatuses(config),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logError("Помилка відповіді на запит стану: %v", err)
+		}
+	})
+}
+
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("HTTP сервер слухає %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logError("Помилка HTTP сервера %s: %v", addr, err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Запускати з go test -race: обробник змінює стан, поки /status, /healthz та Snapshot його читають
+func TestStateConcurrentReadsDuringTransitions(t *testing.T) {
+	config := &Config{EnableRepeatAudio: true, RepeatIntervalMin: 1, RepeatAudioFile: "repeat.mp3", EnableCache: true}
+	e := newTestEvaluator(t, config)
+	settings := &atomic.Pointer[Settings]{}
+	settings.Store(&Settings{Config: config, Location: time.UTC})
+
+	mux := http.NewServeMux()
+	registerStatusHandlers(mux, settings, e.state, time.Minute)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for _, path := range []string{"/status", "/healthz"} {
+		readers.Add(1)
+		go func(path string) {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				resp, err := http.Get(server.URL + path)
+				if err != nil {
+					t.Errorf("GET %s: %v", path, err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}(path)
+	}
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			snapshot := e.state.Snapshot()
+			for alertType := range snapshot.ActiveAlertTypes {
+				_ = snapshot.LastPlayed[alertType]
+			}
+		}
+	}()
+
+	// Тривоги почергово починаються і закінчуються
+	for i := 0; i < 50; i++ {
+		now := time.Now().UTC().Format(time.RFC3339)
+		if i%2 == 0 {
+			e.process(FetchResult{Alerts: []Alert{{Type: "AIR", LastUpdate: now}, {Type: "ARTILLERY", LastUpdate: now}}, LastUpdate: now})
+		} else {
+			e.process(FetchResult{LastUpdate: now})
+		}
+	}
+	close(done)
+	readers.Wait()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AlertStats - статистика тривог одного типу за добу
+type AlertStats struct {
+	Count      int   `json:"count"`
+	TotalSec   int64 `json:"total_sec"`
+	LongestSec int64 `json:"longest_sec"`
+}
+
+// Найменше допустиме запізнення підсумку, після якого він вважається пропущеним
+const summaryGrace = 5 * time.Minute
+
+// Допустиме запізнення підсумку з урахуванням паузи між запитами: підсумок перевіряється
+// лише при обробці відповіді, тож при рідких запитах він не має вважатися пропущеним
+func summaryGraceFor(config *Config) time.Duration {
+	longest := max(pollInterval(config, false), pollInterval(config, true), maxBackoffFor(config))
+	return max(summaryGrace, 2*longest+time.Duration(max(config.RequestJitterSec, 0))*time.Second)
+}
+
+// Враховує завершену тривогу у добовій статистиці
+func recordAlertStats(state *State, alertType string, duration time.Duration) {
+	stats, ok := state.DailyStats[alertType]
+	if !ok {
+		stats = &AlertStats{}
+		state.DailyStats[alertType] = stats
+	}
+	seconds := int64(duration.Seconds())
+	stats.Count++
+	stats.TotalSec += seconds
+	stats.LongestSec = max(stats.LongestSec, seconds)
+}
+
+// Форматує тривалість у вигляді "1г 23хв"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours > 0 {
+		return fmt.Sprintf("%dг %dхв", hours, minutes)
+	}
+	return fmt.Sprintf("%dхв", minutes)
+}
+
+func composeDailySummary(stats map[string]*AlertStats) string {
+	if len(stats) == 0 {
+		return "тривог не було"
+	}
+
+	types := make([]string, 0, len(stats))
+	for alertType := range stats {
+		types = append(types, alertType)
+	}
+	sort.Strings(types)
+
+	parts := make([]string, 0, len(types))
+	for _, alertType := range types {
+		s := stats[alertType]
+		parts = append(parts, fmt.Sprintf("%s: %d, загалом %s, найдовша %s", alertType, s.Count,
+			formatDuration(time.Duration(s.TotalSec)*time.Second), formatDuration(time.Duration(s.LongestSec)*time.Second)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Формує добовий підсумок у час daily_summary_at і скидає лічильники. Повертає текст
+// підсумку для сповіщень ("" - надсилати нічого) і true, якщо стан змінено
+func checkDailySummary(state *State, config *Config, location *time.Location, now time.Time) (string, bool) {
+	if config.DailySummaryAt == "" {
+		return "", false
+	}
+	at, err := time.Parse("15:04", config.DailySummaryAt)
+	if err != nil {
+		logError("Помилка парсингу daily_summary_at: %v", err)
+		return "", false
+	}
+
+	// Найближчий до поточного моменту запланований час, що вже настав
+	local := now.In(location)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, location)
+	if local.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+
+	// Перший запуск - лише запамʼятовуємо точку відліку
+	if state.SummarySentAt.IsZero() {
+		state.SummarySentAt = now
+		return "", true
+	}
+	if !state.SummarySentAt.Before(scheduled) {
+		return "", false
+	}
+
+	// Програма не працювала у запланований час
+	summary := ""
+	if now.Sub(scheduled) > summaryGraceFor(config) && config.DailySummaryMissed == "skip" {
+		log.Printf("Добовий підсумок за %s пропущено", scheduled.Format("2006-01-02 15:04"))
+	} else {
+		summary = composeDailySummary(state.DailyStats)
+		log.Printf("Добовий підсумок: %s", summary)
+	}
+
+	state.DailyStats = make(map[string]*AlertStats)
+	state.SummarySentAt = now
+	return summary, true
+}
+
+// Надсилає добовий підсумок у Telegram, на пошту та webhook_url
+func notifySummary(config *Config, client *http.Client, event AlertEvent) {
+	text := "Добовий підсумок: " + event.Text
+	if config.InstanceName != "" {
+		text = "[" + config.InstanceName + "] " + text
+	}
+	sendTelegram(config, text)
+	if config.SMTP != nil && config.SMTP.Host != "" && len(config.SMTP.To) > 0 {
+		subject := "Добовий підсумок"
+		if config.InstanceName != "" {
+			subject = "[" + config.InstanceName + "] " + subject
+		}
+		sendEmailAsync(config.SMTP, subject, event.Text+"\n")
+	}
+	sendWebhook(config, client, event)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckDailySummaryBoundary(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skipf("немає бази часових зон: %v", err)
+	}
+	config := &Config{DailySummaryAt: "08:00"}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, kyiv).UTC()
+	}
+
+	state := &State{}
+	migrateState(state)
+
+	// Перший запуск лише запамʼятовує точку відліку
+	if summary, changed := checkDailySummary(state, config, kyiv, at(1, 12, 0)); summary != "" || !changed {
+		t.Fatalf("first run = %q, %v", summary, changed)
+	}
+	recordAlertStats(state, "AIR", 52*time.Minute)
+	recordAlertStats(state, "AIR", 78*time.Minute)
+
+	// До межі доби підсумку немає
+	if summary, changed := checkDailySummary(state, config, kyiv, at(2, 7, 59)); summary != "" || changed {
+		t.Fatalf("before boundary = %q, %v", summary, changed)
+	}
+
+	// Після межі - один підсумок, лічильники скинуто
+	summary, changed := checkDailySummary(state, config, kyiv, at(2, 8, 0))
+	if !changed || !strings.Contains(summary, "AIR: 2, загалом 2г 10хв, найдовша 1г 18хв") {
+		t.Fatalf("at boundary = %q, %v", summary, changed)
+	}
+	if len(state.DailyStats) != 0 {
+		t.Errorf("stats not reset: %v", state.DailyStats)
+	}
+	if summary, _ := checkDailySummary(state, config, kyiv, at(2, 8, 1)); summary != "" {
+		t.Errorf("summary sent twice: %q", summary)
+	}
+
+	// Наступна доба без тривог
+	if summary, _ := checkDailySummary(state, config, kyiv, at(3, 8, 0)); summary != "тривог не було" {
+		t.Errorf("empty day summary = %q", summary)
+	}
+}
+
+func TestCheckDailySummaryMissed(t *testing.T) {
+	scheduled := time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		config   Config
+		now      time.Time
+		wantSent bool
+	}{
+		{"send policy", Config{DailySummaryMissed: "send"}, scheduled.Add(3 * time.Hour), true},
+		{"skip long after", Config{DailySummaryMissed: "skip"}, scheduled.Add(3 * time.Hour), false},
+		{"skip within grace", Config{DailySummaryMissed: "skip"}, scheduled.Add(4 * time.Minute), true},
+		{"skip with slow polling", Config{DailySummaryMissed: "skip", RequestIntervalSec: 600, MaxBackoffSec: 600}, scheduled.Add(15 * time.Minute), true},
+		{"skip beyond slow polling", Config{DailySummaryMissed: "skip", RequestIntervalSec: 600, MaxBackoffSec: 600}, scheduled.Add(25 * time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.DailySummaryAt = "08:00"
+			state := &State{}
+			migrateState(state)
+			state.SummarySentAt = scheduled.Add(-24 * time.Hour)
+			recordAlertStats(state, "AIR", time.Hour)
+
+			summary, changed := checkDailySummary(state, &config, time.UTC, tt.now)
+			if (summary != "") != tt.wantSent {
+				t.Errorf("summary = %q, want sent %v", summary, tt.wantSent)
+			}
+			if !changed || len(state.DailyStats) != 0 {
+				t.Errorf("changed %v, stats %v: counters must be reset either way", changed, state.DailyStats)
+			}
+		})
+	}
+}
+
+func TestProcessEmitsSummaryEvent(t *testing.T) {
+	e := newTestEvaluator(t, &Config{DailySummaryAt: "00:00"})
+	e.state.SummarySentAt = time.Now().UTC().Add(-48 * time.Hour)
+
+	// Підсумок надсилається навіть тоді, коли запит не вдався
+	events := e.process(FetchResult{Err: errSimulationDone})
+	if len(events) != 1 || events[0].Kind != "summary" || events[0].Text == "" {
+		t.Fatalf("events = %+v, want one summary", events)
+	}
+	if events := e.process(FetchResult{Err: errSimulationDone}); len(events) != 0 {
+		t.Errorf("summary repeated: %+v", events)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package main
+
+import "log"
+
+func setupSyslog(config *Config) {
+	if config.SyslogEnabled {
+		log.Println("Syslog не підтримується на цій платформі")
+	}
+}
+
+func syslogTransition(event string, alertType string, eventTime string) {}
@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+var (
+	sysLogger *syslog.Writer
+	sysLabel  string
+)
+
+func setupSyslog(config *Config) {
+	if !config.SyslogEnabled {
+		return
+	}
+
+	tag := config.SyslogTag
+	if tag == "" {
+		tag = "signal" // Значення за замовчуванням
+	}
+
+	writer, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		logError("Помилка підключення до syslog: %v", err)
+		return
+	}
+	sysLogger = writer
+	sysLabel = fmt.Sprintf("instance=%s run=%s", config.InstanceName, runID)
+}
+
+// Надсилає подію зміни стану у syslog: початок AIR - critical, інші початки - warning, відбій - notice
+func syslogTransition(event string, alertType string, eventTime string) {
+	if sysLogger == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("%s event=%s type=%s time=%s", sysLabel, event, alertType, eventTime)
+	var err error
+	switch {
+	case event == "start" && alertType == "AIR":
+		err = sysLogger.Crit(msg)
+	case event == "start":
+		err = sysLogger.Warning(msg)
+	default:
+		err = sysLogger.Notice(msg)
+	}
+	if err != nil {
+		logError("Помилка запису у syslog: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// Повідомляє systemd про стан програми (Type=notify). Якщо програма запущена
+// не з systemd (немає NOTIFY_SOCKET), нічого не робить
+func notifySystemd(state string) {
+	if _, err := daemon.SdNotify(false, state); err != nil {
+		logWarn("Не вдалося повідомити systemd (%s): %v", state, err)
+	}
+}
+
+// Чи потрібно надсилати сигнал watchdog (у unit-файлі вказано WatchdogSec)
+func systemdWatchdogEnabled() bool {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		logError("Помилка налаштування watchdog systemd: %v", err)
+		return false
+	}
+	return interval > 0
+}
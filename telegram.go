@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Час очікування відповіді Telegram Bot API
+const telegramTimeout = 10 * time.Second
+
+var telegramClient = &http.Client{Timeout: telegramTimeout}
+
+// Формує текст повідомлення про початок або закінчення тривоги
+func telegramMessage(config *Config, event AlertEvent) string {
+	var text string
+	switch event.Kind {
+	case "start":
+		text = fmt.Sprintf("Тривога: %s, початок о %s", event.AlertType, convertToLocalTime(event.Time, config.TimeZone))
+	case "end":
+		text = fmt.Sprintf("Відбій: %s, о %s", event.AlertType, convertToLocalTime(event.Time, config.TimeZone))
+	}
+	if config.InstanceName != "" {
+		text = "[" + config.InstanceName + "] " + text
+	}
+	return text
+}
+
+// Надсилає повідомлення про початок або закінчення тривоги у Telegram
+func notifyTelegram(config *Config, event AlertEvent) {
+	if event.Kind != "start" && event.Kind != "end" {
+		return
+	}
+	sendTelegram(config, telegramMessage(config, event))
+}
+
+// Надсилає довільний текст у Telegram у окремій горутині. Помилка лише записується у лог
+func sendTelegram(config *Config, text string) {
+	if config.TelegramBotToken == "" || config.TelegramChatID == "" {
+		return
+	}
+
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		endpoint := "https://api.telegram.org/bot" + config.TelegramBotToken + "/sendMessage"
+		resp, err := telegramClient.PostForm(endpoint, url.Values{
+			"chat_id": {config.TelegramChatID},
+			"text":    {text},
+		})
+		if err != nil {
+			// Помилка містить адресу запиту разом з токеном, тому токен приховуємо
+			logWarn("Попередження: не вдалося надіслати повідомлення у Telegram: %s", strings.ReplaceAll(err.Error(), config.TelegramBotToken, "***"))
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			logWarn("Попередження: Telegram відповів %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+	}()
+}
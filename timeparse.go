@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Формати часу, які може повертати сервер. Час без часової зони вважається UTC
+var apiTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+}
+
+// Чи записувати у лог формат розібраного часу (опція debug)
+var debugTimeParsing atomic.Bool
+
+// Розбирає час з відповіді сервера, перебираючи відомі формати
+func parseAPITime(value string) (time.Time, error) {
+	for i, layout := range apiTimeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if i > 0 && debugTimeParsing.Load() {
+			log.Printf("Час %q розібрано у форматі %q", value, layout)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("невідомий формат часу %q", value)
+}
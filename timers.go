@@ -0,0 +1,64 @@
+package main
+
+import (
+	"time"
+)
+
+// AlertTimer - таймери активної події для /status та логу
+type AlertTimer struct {
+	Type          string     `json:"type"`
+	Since         *time.Time `json:"since,omitempty"`              // Початок події, якщо відомий
+	ElapsedSec    int64      `json:"elapsed_sec"`                  // Скільки триває подія
+	LastRepeat    *time.Time `json:"last_repeat,omitempty"`        // Останній повторний сигнал
+	NextRepeatSec *int64     `json:"next_repeat_in_sec,omitempty"` // Через скільки лунатиме повтор, якщо він буде
+}
+
+// Обчислює таймери активних подій. Повторний сигнал лунає лише для події з repeatAlertType,
+// тому час до наступного повтору вказується тільки для неї
+func alertTimers(state *State, config *Config, now time.Time) []AlertTimer {
+	repeatType := repeatAlertType(state, config)
+	timers := make([]AlertTimer, 0, len(state.ActiveAlertTypes))
+	for _, alertType := range activeAlertTypes(state) {
+		timer := AlertTimer{Type: alertType}
+		since, hasSince := state.ActiveSince[alertType]
+		if hasSince {
+			timer.Since = &since
+			timer.ElapsedSec = int64(now.Sub(since).Seconds())
+		}
+		// На початку події відлік повторів встановлюється на її початок, тож це ще не повтор
+		if lastPlayed, ok := state.LastPlayed[alertType]; ok && (!hasSince || lastPlayed.After(since)) {
+			timer.LastRepeat = &lastPlayed
+		}
+		if alertType == repeatType && config.EnableRepeatAudio {
+			if interval := repeatIntervalFor(config, alertType); interval > 0 {
+				next := int64(0)
+				if lastPlayed, ok := state.LastPlayed[alertType]; ok {
+					next = max(int64(lastPlayed.Add(time.Duration(interval)*time.Minute).Sub(now).Seconds()), 0)
+				}
+				timer.NextRepeatSec = &next
+			}
+		}
+		timers = append(timers, timer)
+	}
+	return timers
+}
+
+// Записує таймери активних подій у лог (лише з debug)
+func logAlertTimers(state *State, config *Config, now time.Time) {
+	if !config.Debug {
+		return
+	}
+	for _, timer := range alertTimers(state, config, now) {
+		elapsed, lastRepeat, nextRepeat := "невідомо", "не було", "не буде"
+		if timer.Since != nil {
+			elapsed = formatDuration(time.Duration(timer.ElapsedSec) * time.Second)
+		}
+		if timer.LastRepeat != nil {
+			lastRepeat = timer.LastRepeat.Format(time.RFC3339)
+		}
+		if timer.NextRepeatSec != nil {
+			nextRepeat = "через " + formatDuration(time.Duration(*timer.NextRepeatSec)*time.Second)
+		}
+		logDebug("Таймери %s: триває %s, останній повтор %s, наступний повтор %s", timer.Type, elapsed, lastRepeat, nextRepeat)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "log"
+
+// Позначає переходи між типами подій: якщо нова подія TO почалась, коли була активна FROM,
+// і у transition_audio є запис "FROM>TO", замість звичайного звуку початку лунає звук переходу.
+// Відбій FROM у тому ж опитуванні стає частиною переходу і окремо не звучить
+func applyTransitions(events []AlertEvent, previous []string, config *Config) {
+	if len(config.TransitionAudio) == 0 || len(previous) == 0 {
+		return
+	}
+	for i := range events {
+		if events[i].Kind != "start" {
+			continue
+		}
+		for _, from := range previous {
+			key := from + ">" + events[i].AlertType
+			file, ok := config.TransitionAudio[key]
+			if !ok || file == "" {
+				continue
+			}
+			log.Printf("Перехід між подіями %s, звук %s", key, file)
+			events[i].Handoff = key
+			events[i].Audio = file
+			for j := range events {
+				if events[j].Kind == "end" && events[j].AlertType == from {
+					events[j].Handoff = key
+				}
+			}
+			break
+		}
+	}
+}
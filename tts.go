@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TTSConfig - налаштування голосового оголошення тривоги
+type TTSConfig struct {
+	Enabled      bool              `json:"enabled"`
+	Engine       string            `json:"engine"`  // espeak-ng або espeak
+	Voice        string            `json:"voice"`   // Голос рушія, за замовчуванням uk
+	Command      string            `json:"command"` // Власна команда з {text} та {output} замість рушія
+	Template     string            `json:"template"`
+	Labels       map[string]string `json:"labels"`        // Назви типів подій для оголошення
+	ReplaceSiren bool              `json:"replace_siren"` // Оголошення замість звуку події
+	TimeoutSec   int               `json:"timeout_sec"`
+}
+
+// Шаблон оголошення за замовчуванням, наприклад "Повітряна тривога, 14 годин 23 хвилини"
+const defaultTTSTemplate = "{label}, {hours_text} {minutes_text}"
+
+// Вибирає форму слова для числа: 1 година, 2 години, 5 годин
+func ukrainianPlural(n int, one, few, many string) string {
+	if n%100 >= 11 && n%100 <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	default:
+		return many
+	}
+}
+
+// Повертає налаштування оголошення або nil, якщо його вимкнено.
+// announce_time вмикає оголошення з блоком tts, а якщо його не вказано - з типовими налаштуваннями
+func announcementConfig(config *Config) *TTSConfig {
+	switch {
+	case config.TTS != nil && (config.TTS.Enabled || config.AnnounceTime):
+		return config.TTS
+	case config.AnnounceTime:
+		return &TTSConfig{}
+	}
+	return nil
+}
+
+// Формує текст оголошення за шаблоном tts.template. Назва типу береться з tts.labels, потім з type_labels
+func composeAnnouncement(tts *TTSConfig, typeLabels map[string]string, alertType string, local time.Time) string {
+	label := alertType
+	if custom, ok := tts.Labels[alertType]; ok {
+		label = custom
+	} else if custom, ok := typeLabels[alertType]; ok {
+		label = custom
+	}
+	template := tts.Template
+	if template == "" {
+		template = defaultTTSTemplate
+	}
+	hours, minutes := local.Hour(), local.Minute()
+	return strings.NewReplacer(
+		"{label}", label,
+		"{type}", alertType,
+		"{time}", local.Format("15:04"),
+		"{hours}", fmt.Sprint(hours),
+		"{minutes}", fmt.Sprint(minutes),
+		"{hours_text}", fmt.Sprintf("%d %s", hours, ukrainianPlural(hours, "година", "години", "годин")),
+		"{minutes_text}", fmt.Sprintf("%d %s", minutes, ukrainianPlural(minutes, "хвилина", "хвилини", "хвилин")),
+	).Replace(template)
+}
+
+// Синтезує оголошення у тимчасовий WAV файл. Файл треба видалити після відтворення
+func synthesizeAnnouncement(tts *TTSConfig, text string) (string, error) {
+	command := tts.Command
+	if command == "" {
+		engine, voice := tts.Engine, tts.Voice
+		if engine == "" {
+			engine = "espeak-ng" // Значення за замовчуванням
+		}
+		if voice == "" {
+			voice = "uk"
+		}
+		command = engine + " -v " + voice + " -w {output} {text}"
+	}
+
+	output, err := os.CreateTemp("", "signal-tts-*.wav")
+	if err != nil {
+		return "", err
+	}
+	output.Close()
+
+	// Команда виконується без оболонки, текст передається одним аргументом
+	args := strings.Fields(command)
+	for i, arg := range args {
+		args[i] = strings.NewReplacer("{text}", text, "{output}", output.Name()).Replace(arg)
+	}
+
+	timeout := time.Duration(tts.TimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		os.Remove(output.Name())
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return output.Name(), nil
+}
+
+// Готує голосове оголошення початку тривоги. Повертає шлях до файлу або "", якщо оголошення вимкнено чи не вдалося
+func prepareAnnouncement(config *Config, location *time.Location, event AlertEvent) string {
+	tts := announcementConfig(config)
+	if tts == nil {
+		return ""
+	}
+	local := time.Now().In(location)
+	if eventTime, err := parseAPITime(event.Time); err == nil {
+		local = eventTime.In(location)
+	}
+
+	text := composeAnnouncement(tts, config.TypeLabels, event.AlertType, local)
+	path, err := synthesizeAnnouncement(tts, text)
+	if err != nil {
+		logError("Помилка синтезу оголошення %q: %v", text, err)
+		return ""
+	}
+	return path
+}
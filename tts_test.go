@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComposeAnnouncementLabels(t *testing.T) {
+	local := time.Date(2024, 5, 1, 15, 42, 0, 0, time.UTC)
+	typeLabels := map[string]string{"AIR": "Повітряна тривога", "ARTILLERY": "Загроза артобстрілу"}
+	tts := &TTSConfig{Labels: map[string]string{"ARTILLERY": "Артобстріл"}}
+
+	tests := []struct {
+		alertType string
+		template  string
+		want      string
+	}{
+		{"AIR", "", "Повітряна тривога, 15 годин 42 хвилини"},
+		{"ARTILLERY", "", "Артобстріл, 15 годин 42 хвилини"},
+		{"CHEMICAL", "", "CHEMICAL, 15 годин 42 хвилини"},
+		{"AIR", "Air alert, {time}", "Air alert, 15:42"},
+	}
+	for _, tt := range tests {
+		tts.Template = tt.template
+		if got := composeAnnouncement(tts, typeLabels, tt.alertType, local); got != tt.want {
+			t.Errorf("composeAnnouncement(%s, %q) = %q, want %q", tt.alertType, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestAnnouncementConfig(t *testing.T) {
+	if tts := announcementConfig(&Config{}); tts != nil {
+		t.Errorf("announcement enabled without tts and announce_time: %+v", tts)
+	}
+	if tts := announcementConfig(&Config{TTS: &TTSConfig{Voice: "en"}}); tts != nil {
+		t.Errorf("announcement enabled with tts.enabled false: %+v", tts)
+	}
+	if tts := announcementConfig(&Config{AnnounceTime: true}); tts == nil {
+		t.Error("announce_time without tts block did not enable the announcement")
+	}
+	custom := &TTSConfig{Voice: "en"}
+	if tts := announcementConfig(&Config{AnnounceTime: true, TTS: custom}); tts != custom {
+		t.Errorf("announce_time ignored the tts block: %+v", tts)
+	}
+}
+
+// Якщо синтез не вдався, оголошення немає і лунає лише звук події
+func TestPrepareAnnouncementFallback(t *testing.T) {
+	config := &Config{AnnounceTime: true, TTS: &TTSConfig{Command: "/nonexistent/tts {output} {text}"}}
+	event := AlertEvent{Kind: "start", AlertType: "AIR", Time: "2024-05-01T12:42:00Z"}
+	if path := prepareAnnouncement(config, time.UTC, event); path != "" {
+		t.Errorf("prepareAnnouncement = %q, want fallback to the siren", path)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+// Дані збірки, задаються при компіляції:
+// go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// Рядок версії для -version та логу запуску
+func versionString() string {
+	return fmt.Sprintf("signal %s (коміт %s, зібрано %s)", version, commit, buildDate)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookPayload - тіло запиту webhook_url
+type WebhookPayload struct {
+	Event     string   `json:"event"`
+	Type      string   `json:"type"`
+	Time      string   `json:"time"`
+	AllActive []string `json:"all_active"`
+	Summary   string   `json:"summary,omitempty"` // Текст добового підсумку для події summary
+}
+
+// Пауза перед повторною спробою
+const webhookRetryDelay = 5 * time.Second
+
+// Надсилає POST запит на webhook_url при початку та закінченні тривоги і з добовим підсумком.
+// Запит виконується у окремій горутині, при невдачі повторюється один раз
+func sendWebhook(config *Config, client *http.Client, event AlertEvent) {
+	if config.WebhookURL == "" || (event.Kind != "start" && event.Kind != "end" && event.Kind != "summary") {
+		return
+	}
+	postWebhookAsync(config, client, config.WebhookURL, event)
+}
+
+// Надсилає подію escalate на escalate_webhook_url, наприклад, для дзвінка на телефон
+func sendEscalationWebhook(config *Config, client *http.Client, event AlertEvent) {
+	if config.EscalateWebhookURL == "" {
+		return
+	}
+	postWebhookAsync(config, client, config.EscalateWebhookURL, event)
+}
+
+func postWebhookAsync(config *Config, client *http.Client, url string, event AlertEvent) {
+
+	payload := WebhookPayload{
+		Event:     event.Kind,
+		Type:      event.AlertType,
+		Time:      event.Time,
+		AllActive: event.Active,
+		Summary:   event.Text,
+	}
+	if payload.AllActive == nil {
+		payload.AllActive = []string{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logError("Помилка формування запиту webhook: %v", err)
+		return
+	}
+
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		err := postWebhook(config, client, url, body)
+		if err == nil {
+			return
+		}
+		logWarn("Попередження: %v, повтор через %s", err, webhookRetryDelay)
+		time.Sleep(webhookRetryDelay)
+		if err := postWebhook(config, client, url, body); err != nil {
+			logWarn("Попередження: %v", err)
+		}
+	}()
+}
+
+func postWebhook(config *Config, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("помилка запиту webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range config.WebhookHeaders {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("помилка запиту webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook відповів %s", resp.Status)
+	}
+	return nil
+}